@@ -1,27 +1,16 @@
-package main
+package action
 
 import (
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/billglover/buddybot/bot"
 	"github.com/nlopes/slack"
 )
 
-func main() {
-	b, err := bot.New()
-	if err != nil {
-		fmt.Println("ERROR: unable to initiate the bot:", err)
-		os.Exit(1)
-	}
-
-	lambda.Start(handler(b))
-}
-
-func handler(b *bot.SlackBot) bot.APIHandler {
+// Handler returns an APIHandler that handles Slack message actions.
+func Handler(b *bot.SlackBot) bot.APIHandler {
 
 	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
@@ -36,33 +25,31 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 		case "flag":
 
 			// Request access tokens
-			botToken, botUserToken, botUser, err := b.RetrieveTokens(a.Team.Id)
+			botToken, botUserToken, botUser, err := b.RetrieveTokensFor(bot.EnterpriseID(req), a.Team.Id)
 			if err != nil {
 				fmt.Println("WARN: unable to retrieve team access token:", err)
 				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
 				return resp, nil
 			}
 
-			// Searching for the admin channel requires the Bot User token rather than the Bot token.
-			adminGroup := ""
-			api := slack.New(botUserToken)
-			grps, err := api.GetGroups(true)
+			settings, err := b.Store.GetSettings(b.ScoreTeam(bot.EnterpriseID(req), a.Team.Id))
 			if err != nil {
-				fmt.Println("WARN: unable to retrieve list of groups:", err)
+				fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
+			}
+
+			// Searching for the admin channel requires the Bot User token rather than the Bot token.
+			adminGroup, err := bot.AdminChannel(botUserToken, settings)
+			if err != nil && err != bot.ErrNotFound {
+				fmt.Println("WARN: unable to find the admin channel:", err)
 				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
 				return resp, nil
 			}
-			for _, g := range grps {
-				if g.NameNormalized == "admins" {
-					adminGroup = g.ID
-				}
-			}
 
 			// Notify the reporter that we have received their report
-			api = slack.New(botToken)
+			api := slack.New(botToken)
 			_, err = api.PostEphemeral(a.Channel.Id, a.User.Id,
 				slack.MsgOptionPostEphemeral2(a.User.Id),
-				slack.MsgOptionText("This message has been flagged!\nWe'll review it against our Code of Conduct and take appropriate action. If we need more information, one of the admins will be in touch privately for more information.", false),
+				slack.MsgOptionText(settings.Message("flagReporter", bot.MessageData{}, userLocale(botToken, a.User.Id)), false),
 			)
 			if err != nil {
 				fmt.Println("WARN: failed to notify reporter that message was flagged:", err)
@@ -72,7 +59,7 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 
 			// Notify the original author that their message has been flagged
 			api = slack.New(botToken)
-			msgText := "This message that you posted has been flagged as potentially violating our Code of Conduct!\n> \"" + a.OriginalMessage.Text + "\"\n\nThe message may be removed or one of the admins may be in touch shortly to discuss this post. We know that not all CoC breaches are intentional, so please consider reviewing your post and notifying the thread of any changes."
+			msgText := settings.Message("flagAuthor", bot.MessageData{Text: a.OriginalMessage.Text}, userLocale(botToken, a.OriginalMessage.User))
 			_, err = api.PostEphemeral(a.Channel.Id, a.OriginalMessage.User,
 				slack.MsgOptionPostEphemeral2(a.User.Id),
 				slack.MsgOptionText(msgText, false),
@@ -110,10 +97,10 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 			}
 
 			attachment := slack.Attachment{
-				Title:     "Flagged message",
+				Title:     settings.Message("flagTitle", bot.MessageData{}),
 				TitleLink: permalink,
 				Color:     "danger",
-				Pretext:   "The message below has been flagged for a potential CoC violation",
+				Pretext:   settings.Message("flagPretext", bot.MessageData{}),
 				Fields: []slack.AttachmentField{
 					slack.AttachmentField{Title: "Reporter", Value: reporter.Name, Short: true},
 					slack.AttachmentField{Title: "Author", Value: author.Name, Short: true},
@@ -143,3 +130,13 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 		return resp, nil
 	}
 }
+
+// userLocale returns the locale a user has chosen in Slack, or "" if it can't
+// be found.
+func userLocale(token, user string) string {
+	locale, err := bot.UserLocale(token, user)
+	if err != nil {
+		fmt.Println("WARN: unable to find the user's locale, using the workspace's:", err)
+	}
+	return locale
+}
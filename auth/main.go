@@ -1,156 +1,180 @@
-package main
+package auth
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"html/template"
-
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/billglover/buddybot/bot"
 )
 
-// AuthResponse represents the response we receive from Slack when
-// a user adds our app to their workspace.
-type AuthResponse struct {
-	Ok          bool   `json:"ok"`
-	AccessToken string `json:"access_token"`
-	Scope       string `json:"scope"`
-	UserID      string `json:"user_id"`
-	TeamName    string `json:"team_name"`
-	TeamID      string `json:"team_id"`
-	Bot         struct {
-		BotUserID      string `json:"bot_user_id"`
-		BotAccessToken string `json:"bot_access_token"`
-	} `json:"bot"`
-}
-
-// AuthRecord represents the access token we store in DynamoDB for
-// every authenticated workspace.
-type AuthRecord struct {
-	UID            string `json:"uid"`
-	AccessToken    string `json:"access_token"`
-	Scope          string `json:"scope"`
-	UserID         string `json:"user_id"`
-	TeamName       string `json:"team_name"`
-	TeamID         string `json:"team_id"`
-	BotUserID      string `json:"bot_user_id"`
-	BotAccessToken string `json:"bot_access_token"`
-}
+const (
+	// botScopes and userScopes are the permissions BuddyBot requests.
+	botScopes  = "app_mentions:read,channels:history,channels:read,chat:write,commands,groups:history,reactions:read,reactions:write,users:read"
+	userScopes = "groups:read"
 
-func handler(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// stateCookie ties the OAuth state to the browser that started the install.
+	stateCookie = "buddybot_state"
+)
 
-	fmt.Println("INFO:", req.HTTPMethod, req.Path)
-	fmt.Println("INFO:", req.QueryStringParameters)
+// Handler returns an APIHandler for the Slack OAuth flow. Requests to /install
+// render the "Add to Slack" page, and all other requests are treated as the
+// callback Slack makes once a workspace has added BuddyBot.
+func Handler(b *bot.SlackBot) bot.APIHandler {
 
-	b, err := bot.New()
+	t, err := loadPages(b.TemplateDir)
 	if err != nil {
-		fmt.Println("ERROR: unable to initiate the bot:", err)
-		os.Exit(1)
+		fmt.Println("WARN: unable to load page templates, using defaults:", err)
+		t, _ = loadPages("")
 	}
 
-	// change the temporary code for an API access token
-	v := url.Values{}
-	v.Set("code", req.QueryStringParameters["code"])
-	v.Set("name", "https://k1jenua1ml.execute-api.eu-west-1.amazonaws.com/Prod/auth")
-
-	r, err := http.NewRequest(http.MethodPost, "https://slack.com/api/oauth.access", strings.NewReader(v.Encode()))
-	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	r.SetBasicAuth(b.ClientID, b.ClientSecret)
-	client := http.DefaultClient
-	resp, err := client.Do(r)
-	if resp != nil {
-		defer resp.Body.Close()
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+		fmt.Println("INFO:", req.HTTPMethod, req.Path)
+
+		data := page{
+			Brand:    b.Branding,
+			StartURL: baseURL(b, req) + "/install",
+		}
+
+		if strings.HasSuffix(req.Path, "/install") {
+			return install(b, t, req, data)
+		}
+		return callback(b, t, req, data)
 	}
+}
+
+// install renders the "Add to Slack" page. A signed state value is included in
+// the link to Slack and set as a cookie so the callback can confirm the install
+// was started by us, from the same browser.
+func install(b *bot.SlackBot, t *template.Template, req events.APIGatewayProxyRequest, data page) (events.APIGatewayProxyResponse, error) {
+	state, err := b.NewState()
 	if err != nil {
-		fmt.Println("ERROR: unable to request auth token:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+		fmt.Println("ERROR: unable to create OAuth state:", err)
+		data.Message = "Something went wrong preparing your install. Please try again."
+		return render(t, "error.html", http.StatusInternalServerError, data, nil)
 	}
 
-	ar := new(AuthResponse)
-	err = json.NewDecoder(resp.Body).Decode(ar)
-	if err != nil {
-		fmt.Println("ERROR: unable to decode auth token:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+	v := url.Values{}
+	v.Set("client_id", b.ClientID)
+	v.Set("scope", botScopes)
+	v.Set("user_scope", userScopes)
+	v.Set("redirect_uri", baseURL(b, req)+"/auth")
+	v.Set("state", state)
+	data.InstallURL = "https://slack.com/oauth/v2/authorize?" + v.Encode()
+
+	cookie := http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		MaxAge:   int(bot.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secure(b, req),
 	}
 
-	fmt.Println("INFO:", ar)
-
-	// store web-hook payload in DynamoDB
-	record := AuthRecord{
-		UID:            ar.TeamID,
-		UserID:         ar.UserID,
-		AccessToken:    ar.AccessToken,
-		Scope:          ar.Scope,
-		TeamName:       ar.TeamName,
-		TeamID:         ar.TeamID,
-		BotUserID:      ar.Bot.BotUserID,
-		BotAccessToken: ar.Bot.BotAccessToken,
+	return render(t, "install.html", http.StatusOK, data, map[string]string{
+		"Set-Cookie":    cookie.String() + "; SameSite=Lax",
+		"Cache-Control": "no-store",
+	})
+}
+
+// callback completes an install by exchanging the code Slack gives us for
+// access tokens and storing them.
+func callback(b *bot.SlackBot, t *template.Template, req events.APIGatewayProxyRequest, data page) (events.APIGatewayProxyResponse, error) {
+	q := req.QueryStringParameters
+
+	// the user chose not to install
+	if e := q["error"]; e != "" {
+		fmt.Println("INFO: install cancelled:", e)
+		data.Message = "The install was cancelled, so " + b.Branding.Name + " hasn't been added to your workspace."
+		return render(t, "error.html", http.StatusForbidden, data, nil)
 	}
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(b.Region)},
-	)
 
+	// reject callbacks we didn't start, before the code is used
+	state := q["state"]
+	err := b.VerifyState(state)
+	if err == nil && stateFromCookie(req) != state {
+		err = bot.ErrInvalidState
+	}
 	if err != nil {
-		fmt.Println("ERROR: unable to create DynamoDB session:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+		fmt.Println("WARN: rejected install callback:", err)
+		data.Message = "We couldn't confirm this install request came from " + b.Branding.Name + ". It may have expired, or been started in another browser."
+		return render(t, "error.html", http.StatusBadRequest, data, nil)
 	}
 
-	ddb := dynamodb.New(sess)
-
-	payload, err := dynamodbattribute.MarshalMap(record)
+	// change the temporary code for API access tokens
+	ar, err := b.ExchangeCode(q["code"], baseURL(b, req)+"/auth")
 	if err != nil {
-		fmt.Println("ERROR: unable to marshal DynamoDB record:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+		fmt.Println("WARN: unable to request auth token:", err)
+		data.Message = "Slack didn't accept the install request. The link may have been used already."
+		return render(t, "error.html", http.StatusBadRequest, data, nil)
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      payload,
-		TableName: aws.String(b.AuthTable),
-	}
+	fmt.Println("INFO: installed in team", ar.Team.ID, "(", ar.Team.Name, ") by", ar.AuthedUser.ID)
+
+	// store the install record, including token expiry for rotated tokens
+	record := bot.NewAuthRecord(ar, time.Now())
 
-	_, err = ddb.PutItem(input)
+	err = b.Store.PutAuth(record)
 	if err != nil {
-		fmt.Println("ERROR: unable to put record in DynamoDB:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+		fmt.Println("ERROR: unable to store auth record:", err)
+		data.Message = "Something went wrong saving your install. Please try again."
+		return render(t, "error.html", http.StatusInternalServerError, data, nil)
 	}
 
-	fmt.Println("INFO: successfully put record in DynamoDB:")
+	fmt.Println("INFO: successfully stored auth record")
 
-	pageBuf := new(bytes.Buffer)
-	t := template.Must(template.New("t1").
-		Parse("<html><body><h1>BuddyBot</h1><p>Successfully authenticated for: {{.}}</p></body></html>"))
-	err = t.Execute(pageBuf, record.TeamName)
-	if err != nil {
-		fmt.Println("ERROR: unable to render template:", err)
-		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
-		return apiResp, nil
+	data.TeamName = record.TeamName
+	return render(t, "success.html", http.StatusOK, data, map[string]string{
+		"Set-Cookie": (&http.Cookie{Name: stateCookie, MaxAge: -1, HttpOnly: true, Secure: secure(b, req)}).String(),
+	})
+}
+
+// baseURL returns the public URL BuddyBot is served from. It comes from the
+// configuration if set, otherwise it is derived from the request.
+func baseURL(b *bot.SlackBot, req events.APIGatewayProxyRequest) string {
+	if b.BaseURL != "" {
+		return strings.TrimSuffix(b.BaseURL, "/")
 	}
 
-	apiResp := events.APIGatewayProxyResponse{
-		Body:       pageBuf.String(),
-		StatusCode: http.StatusSeeOther,
-		Headers:    map[string]string{"Location": "http://me.billglover.buddybot.static.s3-website-eu-west-1.amazonaws.com/success.html"},
+	scheme := header(req, "X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "https"
 	}
 
-	return apiResp, nil
+	u := scheme + "://" + header(req, "Host")
+	if stage := req.RequestContext.Stage; stage != "" {
+		u += "/" + stage
+	}
+	return u
 }
 
-func main() {
-	lambda.Start(handler)
+// secure reports whether BuddyBot is served over HTTPS, so that cookies
+// still work when running locally over plain HTTP.
+func secure(b *bot.SlackBot, req events.APIGatewayProxyRequest) bool {
+	return strings.HasPrefix(baseURL(b, req), "https://")
+}
+
+// stateFromCookie returns the OAuth state stored in the request cookies.
+func stateFromCookie(req events.APIGatewayProxyRequest) string {
+	r := http.Request{Header: http.Header{"Cookie": {header(req, "Cookie")}}}
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// header returns a request header. API Gateway passes header names through as
+// sent, so the lookup ignores case.
+func header(req events.APIGatewayProxyRequest, name string) string {
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
 }
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/billglover/buddybot/bot"
+)
+
+func TestInstallPage(t *testing.T) {
+	b := &bot.SlackBot{ClientID: "123.456", ClientSecret: "secret", Branding: bot.Branding{Name: "KudosBot"}}
+	h := Handler(b)
+
+	resp, _ := h(events.APIGatewayProxyRequest{
+		HTTPMethod:     http.MethodGet,
+		Path:           "/install",
+		Headers:        map[string]string{"Host": "example.com"},
+		RequestContext: events.APIGatewayProxyRequestContext{Stage: "Prod"},
+	})
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("should render the install page, got:", resp.StatusCode)
+	}
+	if !strings.Contains(resp.Body, "KudosBot") {
+		t.Error("should apply branding")
+	}
+	if !strings.Contains(resp.Body, "redirect_uri=https%3A%2F%2Fexample.com%2FProd%2Fauth") {
+		t.Error("should derive the redirect URL from the request")
+	}
+	if !strings.HasPrefix(resp.Headers["Set-Cookie"], stateCookie+"=") || !strings.Contains(resp.Headers["Set-Cookie"], "Secure") {
+		t.Error("should set a secure state cookie")
+	}
+}
+
+func TestInstallPageOverHTTP(t *testing.T) {
+	b := &bot.SlackBot{ClientID: "123.456", ClientSecret: "secret"}
+	h := Handler(b)
+
+	resp, _ := h(events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/install",
+		Headers:    map[string]string{"Host": "localhost:8080", "X-Forwarded-Proto": "http"},
+	})
+
+	if !strings.Contains(resp.Body, "redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Fauth") {
+		t.Error("should redirect back over HTTP")
+	}
+	if c := resp.Headers["Set-Cookie"]; !strings.HasPrefix(c, stateCookie+"=") || strings.Contains(c, "Secure") {
+		t.Error("should set a state cookie that is sent over HTTP, got:", c)
+	}
+}
+
+func TestCallbackErrors(t *testing.T) {
+	b := &bot.SlackBot{ClientSecret: "secret", BaseURL: "https://example.com/Prod", Branding: bot.Branding{Name: "BuddyBot"}}
+	h := Handler(b)
+
+	state, _ := b.NewState()
+
+	testCases := []struct {
+		name   string
+		query  map[string]string
+		cookie string
+		status int
+	}{
+		{name: "install denied", query: map[string]string{"error": "access_denied"}, status: http.StatusForbidden},
+		{name: "missing state", query: map[string]string{"code": "abc"}, status: http.StatusBadRequest},
+		{name: "forged state", query: map[string]string{"code": "abc", "state": "forged"}, status: http.StatusBadRequest},
+		{name: "state without cookie", query: map[string]string{"code": "abc", "state": state}, status: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := h(events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				Path:                  "/auth",
+				QueryStringParameters: tc.query,
+				Headers:               map[string]string{"cookie": tc.cookie},
+			})
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("should return %d, got %d", tc.status, resp.StatusCode)
+			}
+			if !strings.Contains(resp.Body, `href="https://example.com/Prod/install"`) {
+				t.Error("should render the error page with a link to start again")
+			}
+		})
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "error.html"), []byte(`{{template "header" .}}<p class="custom">{{.Message}}</p>{{template "footer" .}}`), 0600)
+
+	tmpl, err := loadPages(dir)
+	if err != nil {
+		t.Fatal("should load overrides:", err)
+	}
+
+	resp, _ := render(tmpl, "error.html", http.StatusBadRequest, page{Brand: bot.Branding{Name: "BuddyBot"}, Message: "oops"}, nil)
+	if !strings.Contains(resp.Body, `<p class="custom">oops</p>`) {
+		t.Error("should use the overriding template, got:", resp.Body)
+	}
+
+	resp, _ = render(tmpl, "success.html", http.StatusOK, page{Brand: bot.Branding{Name: "BuddyBot"}}, nil)
+	if !strings.Contains(resp.Body, "is now authorised") {
+		t.Error("should keep built-in templates that aren't overridden")
+	}
+}
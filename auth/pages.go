@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// page is the data available to every page template.
+type page struct {
+	Brand      bot.Branding
+	InstallURL string
+	StartURL   string
+	TeamName   string
+	Message    string
+}
+
+// loadPages parses the built-in page templates. If dir is set, any templates
+// in it replace the built-in templates of the same name, so a deployment can
+// restyle some pages without copying all of them.
+func loadPages(dir string) (*template.Template, error) {
+	t := template.New("pages")
+	for name, text := range defaultPages {
+		_, err := t.New(name).Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse built-in template '%s'", name)
+		}
+	}
+
+	if dir == "" {
+		return t, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, errors.Wrap(err, "unable to read template directory")
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) == 0 {
+		return t, nil
+	}
+
+	t, err = t.ParseFiles(overrides...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse template overrides")
+	}
+	return t, nil
+}
+
+// render executes the named page template and returns it as an HTML response.
+func render(t *template.Template, name string, status int, data page, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	pageBuf := new(bytes.Buffer)
+	err := t.ExecuteTemplate(pageBuf, name, data)
+	if err != nil {
+		fmt.Println("ERROR: unable to render template:", err)
+		apiResp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+		return apiResp, nil
+	}
+
+	h := map[string]string{"Content-Type": "text/html; charset=utf-8"}
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	apiResp := events.APIGatewayProxyResponse{
+		Body:       pageBuf.String(),
+		StatusCode: status,
+		Headers:    h,
+	}
+	return apiResp, nil
+}
@@ -0,0 +1,47 @@
+package auth
+
+// defaultPages holds the page templates shipped with BuddyBot, by file name.
+// A template directory can replace any of them with a file of the same name.
+var defaultPages = map[string]string{
+	"layout.html": `{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta http-equiv="X-UA-Compatible" content="ie=edge">
+    <title>{{.Brand.Name}}</title>
+</head>
+
+<body>
+    {{if .Brand.LogoURL}}<img alt="{{.Brand.Name}}" height="64" src="{{.Brand.LogoURL}}" />{{end}}
+    <h1>{{.Brand.Name}}</h1>
+{{end}}
+
+{{define "footer"}}
+</body>
+
+</html>
+{{end}}
+`,
+
+	"install.html": `{{template "header" .}}
+    <p>You can add {{.Brand.Name}} to your Slack workspace by clicking the button below.</p>
+    <a href="{{.InstallURL}}">
+        <img alt="Add to Slack" height="40" width="139" src="https://platform.slack-edge.com/img/add_to_slack.png" srcset="https://platform.slack-edge.com/img/add_to_slack.png 1x, https://platform.slack-edge.com/img/add_to_slack@2x.png 2x"
+        />
+    </a>
+{{template "footer" .}}
+`,
+
+	"success.html": `{{template "header" .}}
+    <p>{{.Brand.Name}} is now authorised to access {{if .TeamName}}{{.TeamName}}{{else}}your Slack workspace{{end}}.</p>
+{{template "footer" .}}
+`,
+
+	"error.html": `{{template "header" .}}
+    <p>{{.Message}}</p>
+    <p><a href="{{.StartURL}}">Try adding {{.Brand.Name}} again</a></p>
+{{template "footer" .}}
+`,
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Award records a single PlusPlus or MinusMinus given to a user, along with
+// where it was given and the reason for it, if any. Awards form an append-only
+// ledger from which every score can be derived.
+//
+// Awards without a giver are adjustments made by BuddyBot itself, such as the
+// opening balance written when scores are first moved onto the ledger.
+type Award struct {
+	Team      string    `json:"team"`
+	Receiver  string    `json:"receiver"`
+	Giver     string    `json:"giver"`
+	Channel   string    `json:"channel"`
+	MessageTS string    `json:"messageTS"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	EventID   string    `json:"eventID"`
+	At        time.Time `json:"at"`
+}
+
+// awardKey returns a key for an award that sorts in the order awards were
+// made. Awards for the same receiver share the prefix awardPrefix(team,
+// receiver).
+func awardKey(a Award) string {
+	return awardPrefix(a.Team, a.Receiver) + awardID(a)
+}
+
+// awardKeyEscaper escapes the separator in receivers, as things may be
+// called anything, so that the awards to "foo:bar" don't share a prefix with
+// those to "foo".
+var awardKeyEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// awardPrefix returns the prefix shared by the keys of every award made to a
+// receiver.
+func awardPrefix(team, receiver string) string {
+	return scoreKey(team, awardKeyEscaper.Replace(receiver)) + ":"
+}
+
+// awardID identifies an award among those made to the same receiver. IDs sort
+// in the order awards were made, and the event and a random suffix keep apart
+// awards the same giver made at the same moment, so that one never replaces
+// another.
+func awardID(a Award) string {
+	return fmt.Sprintf("%020d:%s:%s:%s", a.At.UnixNano(), a.Giver, a.EventID, awardNonce())
+}
+
+// awardSeq numbers awards if random numbers can't be read.
+var awardSeq uint64
+
+// awardNonce returns a random suffix for an award ID.
+func awardNonce() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", atomic.AddUint64(&awardSeq, 1))
+	}
+	return hex.EncodeToString(b)
+}
@@ -8,14 +8,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/nlopes/slack"
 	"github.com/nlopes/slack/slackevents"
 	"github.com/pkg/errors"
@@ -24,85 +21,145 @@ import (
 // APIHandler is a function signature for the AWS API Gatway Request handler
 type APIHandler func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 
+// DefaultMaxRequestAge is how old a request timestamp may be before the request
+// is rejected as stale.
+const DefaultMaxRequestAge = 5 * time.Minute
+
+// DefaultHTTPTimeout is how long BuddyBot waits for Slack to answer the calls
+// it makes directly.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// defaultHTTPClient is used when a SlackBot has no HTTPClient.
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// Errors returned when a request fails validation.
+var (
+	ErrStaleRequest    = errors.New("request timestamp is outside the acceptance window")
+	ErrBadSignature    = errors.New("failed to validate request signature")
+	ErrReplayedRequest = errors.New("request signature has already been seen")
+)
+
 // SlackBot is an instance of the Slack bot
 type SlackBot struct {
 	ClientID     string
 	ClientSecret string
 	BotToken     string
 	UsrToken     string
-	ReqSecret    string
-	Region       string
-	AuthTable    string
-	ScoreTable   string
+	Store        Store
+
+	// ReqSecrets are the active Slack signing secrets. The current secret
+	// comes first, followed by any previous secrets that are still accepted
+	// while a rotation is in progress.
+	ReqSecrets []string
+
+	// MaxRequestAge is the acceptance window for request timestamps. Zero
+	// means DefaultMaxRequestAge.
+	MaxRequestAge time.Duration
+
+	// Clock returns the current time. It is used to check request timestamps
+	// and defaults to time.Now.
+	Clock func() time.Time
+
+	// SeenCache, if set, is used to reject requests whose signature has
+	// already been accepted inside the acceptance window.
+	SeenCache SignatureCache
+
+	// HTTPClient is used for calls BuddyBot makes to Slack itself, such as
+	// exchanging OAuth codes. If nil, a client that gives up after
+	// DefaultHTTPTimeout is used.
+	HTTPClient *http.Client
+
+	// StateSecret signs the OAuth state used during installs. If empty, a
+	// key is derived from ClientSecret.
+	StateSecret string
+
+	// UninstallGracePeriod is how long data is kept after a workspace
+	// uninstalls BuddyBot. Zero means DefaultUninstallGracePeriod.
+	UninstallGracePeriod time.Duration
+
+	// ShareEnterpriseScores pools scores across every workspace in an
+	// Enterprise Grid org rather than keeping them per workspace.
+	ShareEnterpriseScores bool
+
+	// BaseURL is the public URL BuddyBot is served from, e.g.
+	// https://example.com/Prod. If empty it is derived from each request.
+	BaseURL string
+
+	// Branding controls how BuddyBot presents itself on install pages.
+	Branding Branding
+
+	// TemplateDir optionally holds page templates that replace the ones
+	// shipped with BuddyBot.
+	TemplateDir string
+}
+
+// Branding describes how BuddyBot presents itself to users.
+type Branding struct {
+	Name    string
+	LogoURL string
 }
 
-// New returns an instance of a SlackBot. It retrieves credentials from the AWS Parameter Store
-// and reads configuration from environment variables. If it is unable to retrieve the expected
+// New returns an instance of a SlackBot. It loads configuration from the provider
+// chain returned by DefaultProviders. If it is unable to retrieve the expected
 // values it returns an error.
 func New() (*SlackBot, error) {
-	b := new(SlackBot)
-
-	svc := ssm.New(session.New())
-
-	decrypt := true
-	paramsIn := ssm.GetParametersInput{
-		Names: []*string{
-			aws.String("buddybot-botToken"),
-			aws.String("buddybot-usrToken"),
-			aws.String("buddybot-reqSecret"),
-			aws.String("buddybot-clientID"),
-			aws.String("buddybot-clientSecret"),
-		},
-		WithDecryption: &decrypt,
-	}
-
-	paramsOut, err := svc.GetParameters(&paramsIn)
+	providers, err := DefaultProviders()
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to get parameters from AWS parameter store")
-	}
-	params := make(map[string]string, len(paramsOut.Parameters))
-	for _, p := range paramsOut.Parameters {
-		params[*p.Name] = *p.Value
+		return nil, err
 	}
 
-	b.ClientID = params["buddybot-clientID"]
-	if b.ClientID == "" {
-		return nil, errors.New("required parameter 'buddybot-clientID' is undefined")
+	c, err := LoadConfig(providers...)
+	if err != nil {
+		return nil, err
 	}
+	c.Report()
 
-	b.ClientSecret = params["buddybot-clientSecret"]
-	if b.ClientSecret == "" {
-		return nil, errors.New("required parameter 'buddybot-clientSecret' is undefined")
-	}
+	return NewFromConfig(c)
+}
 
-	b.BotToken = params["buddybot-botToken"]
-	if b.BotToken == "" {
-		return nil, errors.New("required parameter 'buddybot-botToken' is undefined")
+// NewFromConfig returns an instance of a SlackBot configured from c.
+func NewFromConfig(c *Config) (*SlackBot, error) {
+	var err error
+
+	b := &SlackBot{
+		ClientID:      c.Get(KeyClientID),
+		ClientSecret:  c.Get(KeyClientSecret),
+		StateSecret:   c.Get(KeyStateSecret),
+		BotToken:      c.Get(KeyBotToken),
+		UsrToken:      c.Get(KeyUsrToken),
+		ReqSecrets:    splitList(c.Get(KeyReqSecret)),
+		MaxRequestAge: c.Duration(KeyMaxRequestAge, DefaultMaxRequestAge),
+
+		UninstallGracePeriod:  c.Duration(KeyUninstallGracePeriod, DefaultUninstallGracePeriod),
+		ShareEnterpriseScores: c.Bool(KeyShareEnterpriseScores),
+
+		BaseURL:     c.Get(KeyBaseURL),
+		TemplateDir: c.Get(KeyTemplateDir),
+		Branding: Branding{
+			Name:    c.Get(KeyBrandName),
+			LogoURL: c.Get(KeyBrandLogoURL),
+		},
 	}
 
-	b.UsrToken = params["buddybot-usrToken"]
-	if b.UsrToken == "" {
-		return nil, errors.New("required parameter 'buddybot-usrToken' is undefined")
+	if b.Branding.Name == "" {
+		b.Branding.Name = "BuddyBot"
 	}
 
-	b.ReqSecret = params["buddybot-reqSecret"]
-	if b.ReqSecret == "" {
-		return nil, errors.New("required parameter 'buddybot-reqSecret' is undefined")
+	if c.Bool(KeyReplayCache) {
+		b.SeenCache = NewMemorySignatureCache()
 	}
 
-	b.Region = os.Getenv("BUDDYBOT_REGION")
-	if b.Region == "" {
-		return nil, errors.New("required environment variable  'BUDDYBOT_REGION' is undefined")
+	b.Store, err = newStore(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialise store")
 	}
 
-	b.AuthTable = os.Getenv("BUDDYBOT_AUTH_TABLE")
-	if b.AuthTable == "" {
-		return nil, errors.New("required environment variable  'BUDDYBOT_AUTH_TABLE' is undefined")
+	keys, err := newKeyProvider(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialise key provider")
 	}
-
-	b.ScoreTable = os.Getenv("BUDDYBOT_SCORE_TABLE")
-	if b.ScoreTable == "" {
-		return nil, errors.New("required environment variable  'BUDDYBOT_SCORE_TABLE' is undefined")
+	if keys != nil {
+		b.Store = &EncryptedStore{Store: b.Store, Keys: keys}
 	}
 
 	return b, nil
@@ -181,7 +238,8 @@ func (b *SlackBot) ParseAction(req events.APIGatewayProxyRequest) (slackevents.M
 }
 
 // ValidateRequest returns an error if the request doesn't pass validation. Validation is
-// performed against the method, headers and signature. It returns nil if the request is valid.
+// performed against the method, headers, timestamp and signature. It returns nil if the
+// request is valid.
 func (b *SlackBot) validateRequest(req events.APIGatewayProxyRequest) error {
 	// we expect all requests to be POST requests
 	if req.HTTPMethod != http.MethodPost {
@@ -199,55 +257,125 @@ func (b *SlackBot) validateRequest(req events.APIGatewayProxyRequest) error {
 		return errors.New("invalid/no 'X-Slack-Signature' header specified")
 	}
 
+	// reject requests sent too long ago, or too far in the future, to stop
+	// captured requests from being replayed
+	sent, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid 'X-Slack-Request-Timestamp' header specified")
+	}
+
+	now := b.now()
+	age := now.Sub(time.Unix(sent, 0))
+	if age > b.maxRequestAge() || age < -b.maxRequestAge() {
+		return errors.Wrapf(ErrStaleRequest, "request sent %s ago", age)
+	}
+
 	// validate the request signature is correct before handling the event
-	valid := checkHMAC(req.Body, ts, sig[3:], b.ReqSecret)
+	idx, valid := checkHMAC(req.Body, ts, sig[3:], b.ReqSecrets)
 	if valid != true {
-		return errors.New("failed to validate request signature")
+		return ErrBadSignature
+	}
+	if idx > 0 {
+		fmt.Printf("INFO: request signature matched rotating secret %d of %d\n", idx+1, len(b.ReqSecrets))
+	}
+
+	// reject identical requests seen inside the acceptance window
+	if b.SeenCache != nil && b.SeenCache.Seen(sig, now, time.Unix(sent, 0).Add(b.maxRequestAge())) {
+		return ErrReplayedRequest
 	}
 
 	return nil
 }
 
-// RetrieveTokens queries the AuthTable to identify the auth tokens for a given
-// Slack team. It returns the bot token and the bot user token and the userID or
-// an error if it is unable to find the token.
-func (b *SlackBot) RetrieveTokens(teamID string) (string, string, string, error) {
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(b.Region)})
-	if err != nil {
-		return "", "", "", err
+// now returns the current time according to the bot's clock.
+func (b *SlackBot) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
 	}
+	return b.Clock()
+}
 
-	ddb := dynamodb.New(sess)
+// httpClient returns the client used for calls to Slack.
+func (b *SlackBot) httpClient() *http.Client {
+	if b.HTTPClient == nil {
+		return defaultHTTPClient
+	}
+	return b.HTTPClient
+}
 
-	input := &dynamodb.GetItemInput{
-		TableName: aws.String(b.AuthTable),
-		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(teamID)}},
+// maxRequestAge returns the acceptance window for request timestamps.
+func (b *SlackBot) maxRequestAge() time.Duration {
+	if b.MaxRequestAge <= 0 {
+		return DefaultMaxRequestAge
 	}
+	return b.MaxRequestAge
+}
+
+// RetrieveTokens queries the Store to identify the auth tokens for a given
+// Slack team. Tokens that have expired, or are about to, are refreshed and the
+// install record updated. It returns the bot token and the bot user token and
+// the userID or an error if it is unable to find the token.
+func (b *SlackBot) RetrieveTokens(teamID string) (string, string, string, error) {
+	return b.RetrieveTokensFor("", teamID)
+}
 
-	result, err := ddb.GetItem(input)
+// RetrieveTokensFor behaves like RetrieveTokens but also takes the Enterprise
+// Grid org the request came from, if any. An org-wide install is preferred
+// over an install in the individual workspace.
+func (b *SlackBot) RetrieveTokensFor(enterpriseID, teamID string) (string, string, string, error) {
+	item, err := b.FindInstall(enterpriseID, teamID)
 	if err != nil {
 		return "", "", "", err
 	}
 
-	item := AuthRecord{}
+	if item.UninstalledAt != 0 {
+		return "", "", "", ErrUninstalled
+	}
 
-	err = dynamodbattribute.UnmarshalMap(result.Item, &item)
+	changed, err := b.refreshTokens(&item)
 	if err != nil {
 		return "", "", "", err
 	}
 
+	if changed {
+		err = b.Store.PutAuth(item)
+		if err != nil {
+			return "", "", "", errors.Wrap(err, "unable to store refreshed tokens")
+		}
+	}
+
 	return item.BotAccessToken, item.AccessToken, item.UserID, nil
 }
 
-// CheckHMAC reports whether msgHMAC is a valid HMAC tag for msg.
-func checkHMAC(body, timestamp, msgHMAC, key string) bool {
+// CheckHMAC reports whether msgHMAC is a valid HMAC tag for msg under any of
+// the given keys. It also returns the index of the key that matched.
+func checkHMAC(body, timestamp, msgHMAC string, keys []string) (int, bool) {
 	msg := "v0:" + timestamp + ":" + body
-	hash := hmac.New(sha256.New, []byte(key))
-	hash.Write([]byte(msg))
-
-	expectedKey := hash.Sum(nil)
 	actualKey, _ := hex.DecodeString(msgHMAC)
-	return hmac.Equal(expectedKey, actualKey)
+
+	for i, key := range keys {
+		hash := hmac.New(sha256.New, []byte(key))
+		hash.Write([]byte(msg))
+
+		expectedKey := hash.Sum(nil)
+		if hmac.Equal(expectedKey, actualKey) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// splitList splits a comma separated parameter, such as an SSM StringList,
+// into its non-empty values.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // NullComparator is a dummy comparator that allows us to define an
@@ -261,16 +389,37 @@ func (c NullComparator) Verify(string) bool {
 	return true
 }
 
-// AuthRecord represents the access token we store in DynamoDB for
-// every authenticated workspace.
+// AuthRecord represents the access token we store for every
+// authenticated workspace.
 // TODO: consider whether this should go in a separate records package
 type AuthRecord struct {
-	UID            string `json:"uid"`
-	AccessToken    string `json:"access_token"`
-	Scope          string `json:"scope"`
-	UserID         string `json:"user_id"`
-	TeamName       string `json:"team_name"`
-	TeamID         string `json:"team_id"`
-	BotUserID      string `json:"bot_user_id"`
-	BotAccessToken string `json:"bot_access_token"`
+	UID             string `json:"uid"`
+	AppID           string `json:"app_id,omitempty"`
+	AccessToken     string `json:"access_token"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	TokenExpiry     int64  `json:"token_expiry,omitempty"`
+	Scope           string `json:"scope"`
+	UserID          string `json:"user_id"`
+	TeamName        string `json:"team_name"`
+	TeamID          string `json:"team_id"`
+	EnterpriseID    string `json:"enterprise_id,omitempty"`
+	EnterpriseName  string `json:"enterprise_name,omitempty"`
+	OrgInstall      bool   `json:"org_install,omitempty"`
+	BotUserID       string `json:"bot_user_id"`
+	BotAccessToken  string `json:"bot_access_token"`
+	BotRefreshToken string `json:"bot_refresh_token,omitempty"`
+	BotTokenExpiry  int64  `json:"bot_token_expiry,omitempty"`
+	BotScope        string `json:"bot_scope,omitempty"`
+
+	// Workspaces lists the workspaces an org-wide install has served.
+	Workspaces []string `json:"workspaces,omitempty"`
+
+	// UninstalledAt is the Unix time at which the workspace uninstalled
+	// BuddyBot. The record is kept, without tokens, until it is purged.
+	UninstalledAt int64 `json:"uninstalled_at,omitempty"`
+
+	// KeyID and DataKey are set when the tokens above are encrypted. KeyID
+	// identifies the master key and DataKey is the wrapped data key.
+	KeyID   string `json:"key_id,omitempty"`
+	DataKey string `json:"data_key,omitempty"`
 }
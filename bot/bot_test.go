@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pkg/errors"
+)
+
+// signedRequest returns a request signed with secret at the given time.
+func signedRequest(secret string, sent time.Time, body string) events.APIGatewayProxyRequest {
+	ts := strconv.FormatInt(sent.Unix(), 10)
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte("v0:" + ts + ":" + body))
+
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       body,
+		Headers: map[string]string{
+			"X-Slack-Request-Timestamp": ts,
+			"X-Slack-Signature":         "v0=" + hex.EncodeToString(hash.Sum(nil)),
+		},
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+
+	testCases := []struct {
+		name string
+		req  events.APIGatewayProxyRequest
+		err  error
+	}{
+		{
+			name: "valid request",
+			req:  signedRequest("secret", now.Add(-time.Minute), "body"),
+		},
+		{
+			name: "stale request",
+			req:  signedRequest("secret", now.Add(-6*time.Minute), "body"),
+			err:  ErrStaleRequest,
+		},
+		{
+			name: "request from the future",
+			req:  signedRequest("secret", now.Add(6*time.Minute), "body"),
+			err:  ErrStaleRequest,
+		},
+		{
+			name: "bad signature",
+			req:  signedRequest("wrong", now, "body"),
+			err:  ErrBadSignature,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &SlackBot{ReqSecrets: []string{"secret"}, Clock: func() time.Time { return now }}
+
+			err := b.validateRequest(tc.req)
+			if errors.Cause(err) != tc.err {
+				t.Errorf("should return %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateRequestReplay(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	b := &SlackBot{
+		ReqSecrets: []string{"secret"},
+		Clock:      func() time.Time { return now },
+		SeenCache:  NewMemorySignatureCache(),
+	}
+
+	req := signedRequest("secret", now, "body")
+	if err := b.validateRequest(req); err != nil {
+		t.Fatal("should accept the first request:", err)
+	}
+
+	if err := b.validateRequest(req); err != ErrReplayedRequest {
+		t.Error("should reject an identical request, got:", err)
+	}
+
+	if err := b.validateRequest(signedRequest("secret", now, "other")); err != nil {
+		t.Error("should accept a different request:", err)
+	}
+}
+
+func TestValidateRequestRotation(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	b := &SlackBot{
+		ReqSecrets: []string{"current", "previous"},
+		Clock:      func() time.Time { return now },
+	}
+
+	for _, secret := range b.ReqSecrets {
+		if err := b.validateRequest(signedRequest(secret, now, "body")); err != nil {
+			t.Errorf("should accept requests signed with %s secret: %v", secret, err)
+		}
+	}
+
+	if err := b.validateRequest(signedRequest("retired", now, "body")); err != ErrBadSignature {
+		t.Error("should reject requests signed with a retired secret, got:", err)
+	}
+}
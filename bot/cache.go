@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// SignatureCache records request signatures that have already been accepted.
+type SignatureCache interface {
+	// Seen reports whether sig has been seen before now. If it hasn't, sig
+	// is recorded and remembered until expiry.
+	Seen(sig string, now, expiry time.Time) bool
+}
+
+// MemorySignatureCache is a SignatureCache held in process memory. Expired
+// signatures are pruned as new ones are added.
+type MemorySignatureCache struct {
+	mu    sync.Mutex
+	sigs  map[string]time.Time
+	added int
+}
+
+// NewMemorySignatureCache returns an empty MemorySignatureCache.
+func NewMemorySignatureCache() *MemorySignatureCache {
+	return &MemorySignatureCache{
+		sigs: make(map[string]time.Time),
+	}
+}
+
+// Seen reports whether sig has been seen before and hasn't yet expired.
+func (c *MemorySignatureCache) Seen(sig string, now, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exp, ok := c.sigs[sig]; ok && now.Before(exp) {
+		return true
+	}
+
+	c.sigs[sig] = expiry
+
+	// prune periodically rather than on every call
+	c.added++
+	if c.added%100 == 0 {
+		for k, exp := range c.sigs {
+			if !now.Before(exp) {
+				delete(c.sigs, k)
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+)
+
+// Defaults for reporting point trading.
+const (
+	DefaultCollusionWindow    = 30 * 24 * time.Hour
+	DefaultCollusionThreshold = 5
+)
+
+// Pattern is a group of users who have given each other points. Counts[i] is
+// the number of awards Users[i] gave to the next user in the group, wrapping
+// round to the first. A pair is two users giving to each other; larger groups
+// give in a ring.
+type Pattern struct {
+	Users  []string
+	Counts []int
+}
+
+// FindCollusion looks for users trading points in the awards given. Pairs who
+// have each given the other at least threshold awards, and rings of three
+// users where each has given the next at least threshold awards, are
+// returned. Only positive awards between users are counted.
+func FindCollusion(awards []Award, threshold int) []Pattern {
+	given := make(map[string]map[string]int)
+	for _, a := range awards {
+		if a.Giver == "" || a.Giver == a.Receiver || a.Amount <= 0 || IsThing(a.Receiver) {
+			continue
+		}
+		if given[a.Giver] == nil {
+			given[a.Giver] = make(map[string]int)
+		}
+		given[a.Giver][a.Receiver]++
+	}
+
+	count := func(from, to string) int {
+		return given[from][to]
+	}
+
+	var patterns []Pattern
+	for a, receivers := range given {
+		for b, ab := range receivers {
+			if ab < threshold {
+				continue
+			}
+
+			// report each pair once, from the lower user ID
+			if ba := count(b, a); a < b && ba >= threshold {
+				patterns = append(patterns, Pattern{Users: []string{a, b}, Counts: []int{ab, ba}})
+			}
+
+			// report each ring once, starting from the lowest user ID
+			for c, bc := range given[b] {
+				if c == a || bc < threshold || a > b || a > c {
+					continue
+				}
+				if ca := count(c, a); ca >= threshold {
+					patterns = append(patterns, Pattern{Users: []string{a, b, c}, Counts: []int{ab, bc, ca}})
+				}
+			}
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].Users) != len(patterns[j].Users) {
+			return len(patterns[i].Users) < len(patterns[j].Users)
+		}
+		return strings.Join(patterns[i].Users, ",") < strings.Join(patterns[j].Users, ",")
+	})
+	return patterns
+}
+
+// FindCollusionSince reads the ledger and looks for users trading points in
+// each team since the time given. Teams without any patterns are omitted, as
+// are the teams holding the scores of things, which can't trade points. It
+// reads the whole ledger so should only be used by maintenance tasks.
+func (b *SlackBot) FindCollusionSince(since time.Time, threshold int) (map[string][]Pattern, error) {
+	awards := make(map[string][]Award)
+	err := b.Store.ForEachAward(func(a Award) error {
+		if !a.At.Before(since) && !IsThingsTeam(a.Team) {
+			awards[a.Team] = append(awards[a.Team], a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read ledger")
+	}
+
+	found := make(map[string][]Pattern)
+	for team, as := range awards {
+		if patterns := FindCollusion(as, threshold); len(patterns) > 0 {
+			found[team] = patterns
+		}
+	}
+	return found, nil
+}
+
+// ReportCollusion looks for users trading points between since and until and
+// posts what it finds to each workspace's admin channel, or prints it if
+// dryRun is set. It returns the number of teams reported on.
+func (b *SlackBot) ReportCollusion(since, until time.Time, threshold int, dryRun bool) (int, error) {
+	found, err := b.FindCollusionSince(since, threshold)
+	if err != nil {
+		return 0, err
+	}
+
+	for team, patterns := range found {
+		fmt.Printf("INFO: found %d patterns in team %s\n", len(patterns), team)
+		msg := CollusionMessage(patterns, since, until, threshold)
+
+		if dryRun {
+			fmt.Println(msg)
+			continue
+		}
+
+		// the team is a workspace or, where scores are shared across an org,
+		// the enterprise, so find the install that covers it
+		ent, ws, err := b.FindScoreTeamInstall(team)
+		if err != nil {
+			fmt.Println("WARN: unable to find the install for team", team, ":", err)
+			continue
+		}
+
+		token, userToken, _, err := b.RetrieveTokensFor(ent, ws)
+		if err != nil {
+			fmt.Println("WARN: unable to retrieve access token for team", team, ":", err)
+			continue
+		}
+
+		settings, err := b.Store.GetSettings(team)
+		if err != nil {
+			fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
+		}
+
+		channel, err := AdminChannel(userToken, settings)
+		if err != nil {
+			fmt.Println("WARN: unable to find the admin channel for team", team, ":", err)
+			continue
+		}
+
+		params := slack.PostMessageParameters{}
+		_, _, err = slack.New(token).PostMessage(channel, msg, params)
+		if err != nil {
+			fmt.Println("WARN: unable to post report for team", team, ":", err)
+		}
+	}
+
+	return len(found), nil
+}
+
+// CollusionMessage describes the patterns found between since and until for
+// the admins of a workspace.
+func CollusionMessage(patterns []Pattern, since, until time.Time, threshold int) string {
+	lines := []string{
+		fmt.Sprintf("*Possible point trading* between %s and %s, where people gave each other at least %d awards:",
+			since.UTC().Format("2 Jan 2006"), until.UTC().Format("2 Jan 2006"), threshold),
+	}
+
+	for _, p := range patterns {
+		users := make([]string, len(p.Users))
+		for i, u := range p.Users {
+			users[i] = fmt.Sprintf("<@%s>", u)
+		}
+
+		if len(p.Users) == 2 {
+			lines = append(lines, fmt.Sprintf("• %s ⇄ %s: %d and %d awards", users[0], users[1], p.Counts[0], p.Counts[1]))
+			continue
+		}
+
+		counts := make([]string, len(p.Counts))
+		for i, c := range p.Counts {
+			counts[i] = fmt.Sprint(c)
+		}
+		lines = append(lines, fmt.Sprintf("• %s → %s: %s awards", strings.Join(users, " → "), users[0], strings.Join(counts, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// AdminChannel returns the channel where messages for a workspace's admins
+// are posted. This is the channel chosen in the workspace settings or, if
+// none has been chosen, the private channel named "admins". Searching private
+// channels requires the user token. It returns ErrNotFound if there is no
+// admin channel.
+func AdminChannel(userToken string, s Settings) (string, error) {
+	if s.AdminChannel != "" {
+		return s.AdminChannel, nil
+	}
+
+	grps, err := slack.New(userToken).GetGroups(true)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to retrieve list of groups")
+	}
+	for _, g := range grps {
+		if g.NameNormalized == "admins" {
+			return g.ID, nil
+		}
+	}
+	return "", ErrNotFound
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// give returns n awards of one point from giver to receiver.
+func give(giver, receiver string, n int) []Award {
+	var awards []Award
+	for i := 0; i < n; i++ {
+		awards = append(awards, Award{Team: "T123", Giver: giver, Receiver: receiver, Amount: 1})
+	}
+	return awards
+}
+
+func TestFindCollusion(t *testing.T) {
+	var awards []Award
+	awards = append(awards, give("U1", "U2", 4)...)
+	awards = append(awards, give("U2", "U1", 3)...)
+	awards = append(awards, give("U3", "U4", 3)...)
+	awards = append(awards, give("U4", "U5", 3)...)
+	awards = append(awards, give("U5", "U3", 5)...)
+	awards = append(awards, give("U6", "U7", 10)...)
+	awards = append(awards, give("U7", "U6", 2)...)
+	awards = append(awards, Award{Team: "T123", Giver: "U7", Receiver: "U6", Amount: -1})
+	awards = append(awards, Award{Team: "T123", Receiver: "U6", Amount: 5, Reason: OpeningBalance})
+	awards = append(awards, give("U8", "kubernetes", 3)...)
+
+	got := FindCollusion(awards, 3)
+	want := []Pattern{
+		{Users: []string{"U1", "U2"}, Counts: []int{4, 3}},
+		{Users: []string{"U3", "U4", "U5"}, Counts: []int{3, 3, 5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("should find the pair and the ring, got %+v", got)
+	}
+
+	if got := FindCollusion(awards, 4); len(got) != 0 {
+		t.Errorf("should ignore patterns below the threshold, got %+v", got)
+	}
+}
+
+func TestFindCollusionSince(t *testing.T) {
+	now := time.Date(2018, 7, 31, 9, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, Clock: func() time.Time { return now }}
+
+	for _, a := range append(give("U1", "U2", 2), give("U2", "U1", 2)...) {
+		a.At = now.AddDate(0, 0, -40)
+		s.AddAward(a)
+	}
+	for _, a := range append(give("U1", "U2", 2), give("U2", "U1", 2)...) {
+		a.At = now.AddDate(0, 0, -1)
+		s.AddAward(a)
+	}
+
+	found, err := b.FindCollusionSince(now.AddDate(0, 0, -30), 3)
+	if err != nil || len(found) != 0 {
+		t.Errorf("should only count awards in the window, got %+v (%v)", found, err)
+	}
+
+	for _, a := range append(give("U1", "U2", 3), give("U2", "U1", 3)...) {
+		a.Team = ThingsTeam("T123")
+		a.At = now.AddDate(0, 0, -1)
+		s.AddAward(a)
+	}
+
+	found, _ = b.FindCollusionSince(now.AddDate(0, 0, -60), 3)
+	if len(found) != 1 || len(found["T123"]) != 1 {
+		t.Errorf("should group patterns by team, ignoring things, got %+v", found)
+	}
+}
+
+func TestCollusionMessage(t *testing.T) {
+	since := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	patterns := []Pattern{
+		{Users: []string{"U1", "U2"}, Counts: []int{4, 3}},
+		{Users: []string{"U3", "U4", "U5"}, Counts: []int{3, 3, 5}},
+	}
+
+	msg := CollusionMessage(patterns, since, since.AddDate(0, 0, 30), 3)
+	for _, want := range []string{
+		"between 1 Jul 2018 and 31 Jul 2018",
+		"at least 3 awards",
+		"• <@U1> ⇄ <@U2>: 4 and 3 awards",
+		"• <@U3> → <@U4> → <@U5> → <@U3>: 3, 3, 5 awards",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("should contain %q, got %q", want, msg)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Configuration keys understood by BuddyBot. Each provider maps these onto its
+// own naming scheme, e.g. "botToken" is read from the BUDDYBOT_BOT_TOKEN
+// environment variable or the "buddybot-botToken" SSM parameter.
+const (
+	KeyClientID      = "clientID"
+	KeyClientSecret  = "clientSecret"
+	KeyBotToken      = "botToken"
+	KeyUsrToken      = "usrToken"
+	KeyReqSecret     = "reqSecret"
+	KeyStore         = "store"
+	KeyStorePath     = "storePath"
+	KeyRegion        = "region"
+	KeyAuthTable     = "authTable"
+	KeyScoreTable    = "scoreTable"
+	KeySettingsTable = "settingsTable"
+	KeyAwardTable    = "awardTable"
+	KeyLimitTable    = "limitTable"
+	KeyEventTable    = "eventTable"
+	KeyMaxRequestAge = "maxRequestAge"
+	KeyReplayCache   = "replayCache"
+	KeyKeyProvider   = "keyProvider"
+	KeyKMSKeyID      = "kmsKeyID"
+	KeyKeyFile       = "keyFile"
+	KeyStateSecret   = "stateSecret"
+
+	KeyUninstallGracePeriod  = "uninstallGracePeriod"
+	KeyShareEnterpriseScores = "shareEnterpriseScores"
+
+	KeyBaseURL      = "baseURL"
+	KeyBrandName    = "brandName"
+	KeyBrandLogoURL = "brandLogoURL"
+	KeyTemplateDir  = "templateDir"
+)
+
+// setting describes a configuration key and how it is validated.
+type setting struct {
+	key      string
+	required bool
+	validate func(v string) error
+}
+
+// settings lists every configuration key BuddyBot reads.
+var settings = []setting{
+	{key: KeyClientID, required: true},
+	{key: KeyClientSecret, required: true},
+	{key: KeyBotToken, required: true},
+	{key: KeyUsrToken, required: true},
+	{key: KeyReqSecret, required: true},
+	{key: KeyStore, validate: oneOf("dynamodb", "bolt", "memory")},
+	{key: KeyStorePath},
+	{key: KeyRegion},
+	{key: KeyAuthTable},
+	{key: KeyScoreTable},
+	{key: KeySettingsTable},
+	{key: KeyAwardTable},
+	{key: KeyLimitTable},
+	{key: KeyEventTable},
+	{key: KeyMaxRequestAge, validate: isDuration},
+	{key: KeyReplayCache, validate: isBool},
+	{key: KeyKeyProvider, validate: oneOf("kms", "local")},
+	{key: KeyKMSKeyID},
+	{key: KeyKeyFile},
+	{key: KeyStateSecret},
+	{key: KeyUninstallGracePeriod, validate: isDuration},
+	{key: KeyShareEnterpriseScores, validate: isBool},
+	{key: KeyBaseURL, validate: isURL},
+	{key: KeyBrandName},
+	{key: KeyBrandLogoURL, validate: isURL},
+	{key: KeyTemplateDir},
+}
+
+// knownKey reports whether k is a configuration key BuddyBot reads.
+func knownKey(k string) bool {
+	for _, s := range settings {
+		if s.key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider is a source of configuration values.
+type Provider interface {
+	// Name identifies the provider in log messages.
+	Name() string
+
+	// Lookup returns the values it holds for the given keys. Keys the
+	// provider doesn't know about are omitted from the result.
+	Lookup(keys []string) (map[string]string, error)
+}
+
+// Config holds configuration values along with the provider that set each one.
+type Config struct {
+	values  map[string]string
+	sources map[string]string
+}
+
+// LoadConfig resolves every known configuration key using the given providers.
+// Providers are consulted in order and the first provider to return a value for
+// a key wins. All values are validated before LoadConfig returns, and every
+// problem found is reported in a single error.
+func LoadConfig(providers ...Provider) (*Config, error) {
+	c := &Config{
+		values:  make(map[string]string),
+		sources: make(map[string]string),
+	}
+
+	for _, p := range providers {
+		var missing []string
+		for _, s := range settings {
+			if _, ok := c.values[s.key]; !ok {
+				missing = append(missing, s.key)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+
+		vals, err := p.Lookup(missing)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read configuration from %s", p.Name())
+		}
+
+		for _, k := range missing {
+			if v, ok := vals[k]; ok && v != "" {
+				c.values[k] = v
+				c.sources[k] = p.Name()
+			}
+		}
+	}
+
+	return c, c.validate()
+}
+
+// Get returns the value for key, or an empty string if it isn't set.
+func (c *Config) Get(key string) string {
+	return c.values[key]
+}
+
+// Source returns the name of the provider that set key.
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// Duration returns the value for key as a time.Duration, or def if it isn't set.
+func (c *Config) Duration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(c.values[key])
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// Bool returns the value for key as a bool, or false if it isn't set.
+func (c *Config) Bool(key string) bool {
+	v, _ := strconv.ParseBool(c.values[key])
+	return v
+}
+
+// Report logs which provider set each configuration value. Values themselves
+// are never logged as many of them are secrets.
+func (c *Config) Report() {
+	keys := make([]string, 0, len(c.sources))
+	for k := range c.sources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Printf("INFO: config '%s' set by %s\n", k, c.sources[k])
+	}
+}
+
+// validate checks required keys are present and that every value is well formed.
+func (c *Config) validate() error {
+	var problems []string
+	for _, s := range settings {
+		v, ok := c.values[s.key]
+		if !ok {
+			if s.required {
+				problems = append(problems, fmt.Sprintf("'%s' is undefined", s.key))
+			}
+			continue
+		}
+
+		if s.validate != nil {
+			if err := s.validate(v); err != nil {
+				problems = append(problems, fmt.Sprintf("'%s' %s (set by %s)", s.key, err, c.sources[s.key]))
+			}
+		}
+	}
+
+	// some keys are only required by a particular store
+	var storeKeys []string
+	switch c.values[KeyStore] {
+	case "", "dynamodb":
+		storeKeys = []string{KeyRegion, KeyAuthTable, KeyScoreTable, KeySettingsTable, KeyAwardTable, KeyLimitTable, KeyEventTable}
+	case "bolt":
+		storeKeys = []string{KeyStorePath}
+	}
+	for _, k := range storeKeys {
+		if _, ok := c.values[k]; !ok {
+			problems = append(problems, fmt.Sprintf("'%s' is undefined", k))
+		}
+	}
+
+	// as are keys needed to encrypt tokens
+	var encKeys []string
+	switch c.values[KeyKeyProvider] {
+	case "kms":
+		encKeys = []string{KeyKMSKeyID, KeyRegion}
+	case "local":
+		encKeys = []string{KeyKeyFile}
+	}
+	for _, k := range encKeys {
+		if _, ok := c.values[k]; !ok {
+			problems = append(problems, fmt.Sprintf("'%s' is undefined", k))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func isDuration(v string) error {
+	if _, err := time.ParseDuration(v); err != nil {
+		return errors.New("is not a valid duration")
+	}
+	return nil
+}
+
+func isBool(v string) error {
+	if _, err := strconv.ParseBool(v); err != nil {
+		return errors.New("is not a valid boolean")
+	}
+	return nil
+}
+
+func isURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("is not an absolute URL")
+	}
+	return nil
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(v string) error {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return errors.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+// DefaultProviders returns the provider chain used by New. The chain can be
+// changed with BUDDYBOT_CONFIG_PROVIDERS, a comma separated list drawn from
+// "env", "secrets", "file" and "ssm". The default is "env", "secrets" and
+// "file", in that order of precedence, followed by "ssm" when running in AWS
+// Lambda. The secrets and file providers are only used when
+// BUDDYBOT_SECRETS_DIR and BUDDYBOT_CONFIG_FILE are set.
+func DefaultProviders() ([]Provider, error) {
+	names := splitList(os.Getenv("BUDDYBOT_CONFIG_PROVIDERS"))
+	if len(names) == 0 {
+		names = []string{"env", "secrets", "file"}
+		if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+			names = append(names, "ssm")
+		}
+	}
+
+	var providers []Provider
+	for _, n := range names {
+		switch n {
+		case "env":
+			providers = append(providers, EnvProvider{Prefix: "BUDDYBOT_"})
+		case "secrets":
+			if dir := os.Getenv("BUDDYBOT_SECRETS_DIR"); dir != "" {
+				providers = append(providers, SecretsDirProvider{Dir: dir})
+			}
+		case "file":
+			if path := os.Getenv("BUDDYBOT_CONFIG_FILE"); path != "" {
+				providers = append(providers, FileProvider{Path: path})
+			}
+		case "ssm":
+			providers = append(providers, SSMProvider{Prefix: "buddybot-"})
+		default:
+			return nil, errors.Errorf("unknown configuration provider '%s'", n)
+		}
+	}
+	return providers, nil
+}
+
+// envName converts a configuration key such as "botToken" into the upper
+// snake case used for environment variables, e.g. "BOT_TOKEN".
+func envName(key string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range key {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvProvider reads configuration from environment variables. The variable
+// for a key is Prefix followed by the key in upper snake case, e.g.
+// BUDDYBOT_AUTH_TABLE for "authTable".
+type EnvProvider struct {
+	Prefix string
+}
+
+// Name identifies the provider in log messages.
+func (p EnvProvider) Name() string {
+	return "env"
+}
+
+// Lookup returns the environment variables set for the given keys.
+func (p EnvProvider) Lookup(keys []string) (map[string]string, error) {
+	vals := make(map[string]string)
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(p.Prefix + envName(k)); ok {
+			vals[k] = v
+		}
+	}
+	return vals, nil
+}
+
+// FileProvider reads configuration from a YAML file of key: value pairs. Lists
+// are joined with commas so that, for example, several signing secrets can be
+// given as a YAML sequence. Keys BuddyBot doesn't read are rejected.
+type FileProvider struct {
+	Path string
+}
+
+// Name identifies the provider in log messages.
+func (p FileProvider) Name() string {
+	return "file " + p.Path
+}
+
+// Lookup returns the values in the file for the given keys.
+func (p FileProvider) Lookup(keys []string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	err = yaml.UnmarshalStrict(data, &raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse config file")
+	}
+
+	// reject misspelt keys rather than quietly using defaults
+	var unknown []string
+	for k := range raw {
+		if !knownKey(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf("unknown keys in config file: %s", strings.Join(unknown, ", "))
+	}
+
+	vals := make(map[string]string)
+	for _, k := range keys {
+		switch v := raw[k].(type) {
+		case nil:
+		case []interface{}:
+			items := make([]string, len(v))
+			for i := range v {
+				items[i] = fmt.Sprint(v[i])
+			}
+			vals[k] = strings.Join(items, ",")
+		default:
+			vals[k] = fmt.Sprint(v)
+		}
+	}
+	return vals, nil
+}
+
+// SecretsDirProvider reads configuration from a directory containing one file
+// per key, such as a Kubernetes secret volume. Leading and trailing whitespace
+// is trimmed from each file.
+type SecretsDirProvider struct {
+	Dir string
+}
+
+// Name identifies the provider in log messages.
+func (p SecretsDirProvider) Name() string {
+	return "secrets " + p.Dir
+}
+
+// Lookup returns the contents of the files named after the given keys.
+func (p SecretsDirProvider) Lookup(keys []string) (map[string]string, error) {
+	vals := make(map[string]string)
+	for _, k := range keys {
+		data, err := ioutil.ReadFile(filepath.Join(p.Dir, k))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		vals[k] = strings.TrimSpace(string(data))
+	}
+	return vals, nil
+}
+
+// SSMProvider reads configuration from the AWS Parameter Store. The parameter
+// for a key is Prefix followed by the key, e.g. "buddybot-botToken". Secure
+// strings are decrypted and StringList parameters are returned comma separated.
+type SSMProvider struct {
+	Prefix string
+}
+
+// Name identifies the provider in log messages.
+func (p SSMProvider) Name() string {
+	return "ssm"
+}
+
+// Lookup returns the parameters that exist for the given keys. If no AWS
+// region or credentials are available there are no parameters to read, so it
+// returns no values rather than an error.
+func (p SSMProvider) Lookup(keys []string) (map[string]string, error) {
+	vals := make(map[string]string)
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create session")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		fmt.Println("WARN: no AWS region configured, skipping the parameter store")
+		return vals, nil
+	}
+	svc := ssm.New(sess)
+
+	// GetParameters accepts at most ten names per call
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > 10 {
+			n = 10
+		}
+
+		names := make([]*string, n)
+		for i, k := range keys[:n] {
+			names[i] = aws.String(p.Prefix + k)
+		}
+		keys = keys[n:]
+
+		paramsOut, err := svc.GetParameters(&ssm.GetParametersInput{
+			Names:          names,
+			WithDecryption: aws.Bool(true),
+		})
+		if aerr, ok := err.(awserr.Error); ok && unavailable(aerr) {
+			fmt.Println("WARN: AWS parameter store unavailable, skipping it:", aerr.Code())
+			return vals, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get parameters from AWS parameter store")
+		}
+
+		for _, param := range paramsOut.Parameters {
+			vals[strings.TrimPrefix(*param.Name, p.Prefix)] = *param.Value
+		}
+	}
+
+	return vals, nil
+}
+
+// unavailable reports whether an AWS error means there is no region or no
+// credentials to use, rather than that a request failed.
+func unavailable(err awserr.Error) bool {
+	switch err.Code() {
+	case aws.ErrMissingRegion.Code(), credentials.ErrNoValidProvidersFoundInChain.Code():
+		return true
+	}
+	return false
+}
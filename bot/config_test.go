@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// staticProvider is a Provider backed by a map.
+type staticProvider map[string]string
+
+func (p staticProvider) Name() string {
+	return "static"
+}
+
+func (p staticProvider) Lookup(keys []string) (map[string]string, error) {
+	return p, nil
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secrets := filepath.Join(dir, "secrets")
+	os.Mkdir(secrets, 0700)
+	ioutil.WriteFile(filepath.Join(secrets, KeyBotToken), []byte("xoxb-secret\n"), 0600)
+
+	file := filepath.Join(dir, "buddybot.yaml")
+	ioutil.WriteFile(file, []byte("botToken: xoxb-file\nusrToken: xoxp-file\nreqSecret:\n  - current\n  - previous\nstore: memory\n"), 0600)
+
+	os.Setenv("BUDDYBOT_TEST_CLIENT_ID", "env-id")
+	defer os.Unsetenv("BUDDYBOT_TEST_CLIENT_ID")
+
+	c, err := LoadConfig(
+		EnvProvider{Prefix: "BUDDYBOT_TEST_"},
+		SecretsDirProvider{Dir: secrets},
+		FileProvider{Path: file},
+		staticProvider{KeyClientID: "static-id", KeyClientSecret: "static-secret"},
+	)
+	if err != nil {
+		t.Fatal("should load a complete configuration:", err)
+	}
+
+	testCases := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{key: KeyClientID, value: "env-id", source: "env"},
+		{key: KeyClientSecret, value: "static-secret", source: "static"},
+		{key: KeyBotToken, value: "xoxb-secret", source: "secrets " + secrets},
+		{key: KeyUsrToken, value: "xoxp-file", source: "file " + file},
+		{key: KeyReqSecret, value: "current,previous", source: "file " + file},
+	}
+
+	for _, tc := range testCases {
+		if v := c.Get(tc.key); v != tc.value {
+			t.Errorf("%s should be %q, got %q", tc.key, tc.value, v)
+		}
+		if s := c.Source(tc.key); s != tc.source {
+			t.Errorf("%s should be set by %q, got %q", tc.key, tc.source, s)
+		}
+	}
+}
+
+func TestFileProviderUnknownKeys(t *testing.T) {
+	f, err := ioutil.TempFile("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary file:", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("botToken: xoxb-file\nsigning_secert: current\n")
+	f.Close()
+
+	_, err = FileProvider{Path: f.Name()}.Lookup([]string{KeyBotToken})
+	if err == nil || !strings.Contains(err.Error(), "signing_secert") {
+		t.Error("should reject unknown keys, got:", err)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	_, err := LoadConfig(staticProvider{
+		KeyClientID:      "id",
+		KeyMaxRequestAge: "five minutes",
+		KeyStore:         "bolt",
+	})
+	if err == nil {
+		t.Fatal("should reject an invalid configuration")
+	}
+
+	for _, want := range []string{"'clientSecret' is undefined", "'maxRequestAge' is not a valid duration", "'storePath' is undefined"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("should report %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	testCases := map[string]string{
+		KeyClientID:      "CLIENT_ID",
+		KeyAuthTable:     "AUTH_TABLE",
+		KeyMaxRequestAge: "MAX_REQUEST_AGE",
+		KeyStore:         "STORE",
+	}
+	for key, want := range testCases {
+		if got := envName(key); got != want {
+			t.Errorf("envName(%q) should be %q, got %q", key, want, got)
+		}
+	}
+}
+
+// setenv sets environment variables for a test, returning a function that
+// restores their previous values.
+func setenv(vars map[string]string) func() {
+	old := make(map[string]*string)
+	for k, v := range vars {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestDefaultProvidersEnvOnly(t *testing.T) {
+	defer setenv(map[string]string{
+		"BUDDYBOT_CONFIG_PROVIDERS": "",
+		"BUDDYBOT_SECRETS_DIR":      "",
+		"BUDDYBOT_CONFIG_FILE":      "",
+		"AWS_LAMBDA_FUNCTION_NAME":  "",
+		"BUDDYBOT_CLIENT_ID":        "id",
+		"BUDDYBOT_CLIENT_SECRET":    "secret",
+		"BUDDYBOT_BOT_TOKEN":        "xoxb-1",
+		"BUDDYBOT_USR_TOKEN":        "xoxp-1",
+		"BUDDYBOT_REQ_SECRET":       "current",
+		"BUDDYBOT_STORE":            "bolt",
+		"BUDDYBOT_STORE_PATH":       "buddybot.db",
+	})()
+
+	providers, err := DefaultProviders()
+	if err != nil {
+		t.Fatal("should build the default chain:", err)
+	}
+	for _, p := range providers {
+		if p.Name() == "ssm" {
+			t.Error("should not use the parameter store outside Lambda")
+		}
+	}
+
+	c, err := LoadConfig(providers...)
+	if err != nil {
+		t.Fatal("should load the configuration from the environment alone:", err)
+	}
+	if c.Get(KeyStore) != "bolt" || c.Source(KeyStore) != "env" {
+		t.Errorf("should read the store from the environment, got %q from %q", c.Get(KeyStore), c.Source(KeyStore))
+	}
+
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "buddybot-event")
+	providers, _ = DefaultProviders()
+	if len(providers) == 0 || providers[len(providers)-1].Name() != "ssm" {
+		t.Error("should fall back to the parameter store in Lambda")
+	}
+}
+
+func TestSSMProviderWithoutRegion(t *testing.T) {
+	defer setenv(map[string]string{
+		"AWS_REGION":          "",
+		"AWS_DEFAULT_REGION":  "",
+		"AWS_SDK_LOAD_CONFIG": "",
+	})()
+
+	vals, err := SSMProvider{Prefix: "buddybot-"}.Lookup([]string{KeyBotToken})
+	if err != nil || len(vals) != 0 {
+		t.Errorf("should return no values without a region, got %v (%v)", vals, err)
+	}
+}
@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+)
+
+// KeyProvider issues and unwraps the data keys used to encrypt workspace tokens.
+// Each install record is encrypted with its own data key, and only the wrapped
+// form of that key is stored alongside the record.
+type KeyProvider interface {
+	// KeyID identifies the master key currently used to wrap new data keys.
+	KeyID() string
+
+	// GenerateDataKey returns a new 256-bit data key in plaintext and wrapped
+	// under the current master key.
+	GenerateDataKey() (plaintext, wrapped []byte, err error)
+
+	// DecryptDataKey unwraps a data key that was wrapped under the master key
+	// identified by keyID.
+	DecryptDataKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KMSKeyProvider is a KeyProvider backed by an AWS KMS customer master key.
+type KMSKeyProvider struct {
+	kms   *kms.KMS
+	keyID string
+}
+
+// NewKMSKeyProvider returns a KeyProvider that wraps data keys with the given
+// KMS key ID, ARN or alias.
+func NewKMSKeyProvider(region, keyID string) (*KMSKeyProvider, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create session")
+	}
+	return &KMSKeyProvider{kms: kms.New(sess), keyID: keyID}, nil
+}
+
+// KeyID identifies the KMS key used to wrap new data keys.
+func (p *KMSKeyProvider) KeyID() string {
+	return p.keyID
+}
+
+// GenerateDataKey asks KMS for a new data key.
+func (p *KMSKeyProvider) GenerateDataKey() ([]byte, []byte, error) {
+	out, err := p.kms.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:   aws.String(p.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate data key")
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// DecryptDataKey asks KMS to unwrap a data key. KMS records the master key in
+// the wrapped key itself, so keyID is only used in error messages.
+func (p *KMSKeyProvider) DecryptDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	out, err := p.kms.Decrypt(&kms.DecryptInput{CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt data key with '%s'", keyID)
+	}
+	return out.Plaintext, nil
+}
+
+// LocalKeyProvider is a KeyProvider for self-hosted deployments. Data keys are
+// wrapped with AES-GCM under master keys read from a local key file.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewLocalKeyProvider reads master keys from path. Each non-empty line of the
+// file holds a key ID and a base64 encoded 256-bit key separated by whitespace.
+// The first key is used for new records; the rest are kept so that records
+// wrapped under older keys can still be read. Lines starting with # are ignored.
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open key file")
+	}
+	defer f.Close()
+
+	p := &LocalKeyProvider{keys: make(map[string][]byte)}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.New("key file lines must contain a key ID and a key")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != 32 {
+			return nil, errors.Errorf("key '%s' must be a base64 encoded 256-bit key", fields[0])
+		}
+
+		if p.current == "" {
+			p.current = fields[0]
+		}
+		p.keys[fields[0]] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to read key file")
+	}
+
+	if p.current == "" {
+		return nil, errors.New("key file contains no keys")
+	}
+
+	return p, nil
+}
+
+// KeyID identifies the master key used to wrap new data keys.
+func (p *LocalKeyProvider) KeyID() string {
+	return p.current
+}
+
+// GenerateDataKey returns a random data key wrapped under the current master key.
+func (p *LocalKeyProvider) GenerateDataKey() ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate data key")
+	}
+
+	wrapped, err := seal(p.keys[p.current], key, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to wrap data key")
+	}
+	return key, wrapped, nil
+}
+
+// DecryptDataKey unwraps a data key using the master key identified by keyID.
+func (p *LocalKeyProvider) DecryptDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := p.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("unknown key '%s'", keyID)
+	}
+
+	key, err := open(master, wrapped, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt data key with '%s'", keyID)
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-GCM and returns the nonce followed by the
+// ciphertext.
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open reverses seal.
+func open(key, ciphertext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
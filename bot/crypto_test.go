@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeKeyFile writes a key file to dir and returns its path.
+func writeKeyFile(t *testing.T, dir, contents string) string {
+	path := filepath.Join(dir, "keys")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal("unable to write key file:", err)
+	}
+	return path
+}
+
+func TestEncryptedStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, err := NewLocalKeyProvider(writeKeyFile(t, dir, "# old key\nk1 MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"))
+	if err != nil {
+		t.Fatal("unable to read key file:", err)
+	}
+
+	raw := NewMemoryStore()
+	raw.PutAuth(AuthRecord{UID: "T000", AccessToken: "xoxp-legacy", BotAccessToken: "xoxb-legacy"})
+
+	s := &EncryptedStore{Store: raw, Keys: keys}
+	rec := AuthRecord{UID: "T123", AccessToken: "xoxp-1", BotAccessToken: "xoxb-1"}
+	if err := s.PutAuth(rec); err != nil {
+		t.Fatal("should store the record:", err)
+	}
+
+	stored, _ := raw.GetAuth("T123")
+	if stored.AccessToken == rec.AccessToken || stored.BotAccessToken == rec.BotAccessToken || stored.KeyID != "k1" {
+		t.Errorf("should store encrypted tokens, got %+v", stored)
+	}
+
+	got, err := s.GetAuth("T123")
+	if err != nil || !reflect.DeepEqual(got, rec) {
+		t.Errorf("should decrypt tokens, got %+v (%v)", got, err)
+	}
+
+	got, err = s.GetAuth("T000")
+	if err != nil || got.BotAccessToken != "xoxb-legacy" {
+		t.Errorf("should read plaintext records, got %+v (%v)", got, err)
+	}
+
+	// tokens are bound to their record
+	swapped := stored
+	swapped.UID = "T999"
+	raw.PutAuth(swapped)
+	if _, err := s.GetAuth("T999"); err == nil {
+		t.Error("should reject tokens moved to another record")
+	}
+	raw.PutAuth(AuthRecord{UID: "T999"}) // leave a readable record for Reencrypt
+
+	// rotate to a new key, keeping the old one for decryption
+	keys, err = NewLocalKeyProvider(writeKeyFile(t, dir, "k2 ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\nk1 MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"))
+	if err != nil {
+		t.Fatal("unable to read key file:", err)
+	}
+	s.Keys = keys
+
+	n, err := s.Reencrypt()
+	if err != nil || n != 3 {
+		t.Errorf("should re-encrypt the old and plaintext records, got %d (%v)", n, err)
+	}
+
+	for _, uid := range []string{"T000", "T123"} {
+		stored, _ = raw.GetAuth(uid)
+		if stored.KeyID != "k2" {
+			t.Errorf("%s should be encrypted with the new key, got '%s'", uid, stored.KeyID)
+		}
+	}
+
+	got, err = s.GetAuth("T123")
+	if err != nil || !reflect.DeepEqual(got, rec) {
+		t.Errorf("should decrypt re-encrypted tokens, got %+v (%v)", got, err)
+	}
+}
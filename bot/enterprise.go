@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EnterpriseID returns the Enterprise Grid org a Slack request came from, or
+// an empty string if it didn't come from a Grid org. It understands Events API
+// callbacks, slash commands and interactive payloads.
+func EnterpriseID(req events.APIGatewayProxyRequest) string {
+	var body struct {
+		EnterpriseID string `json:"enterprise_id"`
+		Enterprise   *struct {
+			ID string `json:"id"`
+		} `json:"enterprise"`
+		Team struct {
+			EnterpriseID string `json:"enterprise_id"`
+		} `json:"team"`
+	}
+
+	// Events API callbacks are JSON, everything else is form encoded
+	err := json.Unmarshal([]byte(req.Body), &body)
+	if err != nil {
+		form, err := url.ParseQuery(req.Body)
+		if err != nil {
+			return ""
+		}
+
+		if id := form.Get("enterprise_id"); id != "" {
+			return id
+		}
+
+		err = json.Unmarshal([]byte(form.Get("payload")), &body)
+		if err != nil {
+			return ""
+		}
+	}
+
+	switch {
+	case body.EnterpriseID != "":
+		return body.EnterpriseID
+	case body.Enterprise != nil:
+		return body.Enterprise.ID
+	default:
+		return body.Team.EnterpriseID
+	}
+}
+
+// FindInstall returns the install record that covers a workspace. An active
+// org-wide install for the enterprise takes precedence over an install in the
+// workspace itself. It returns ErrNotFound if neither exists.
+func (b *SlackBot) FindInstall(enterpriseID, teamID string) (AuthRecord, error) {
+	var org AuthRecord
+	var orgErr error = ErrNotFound
+
+	if enterpriseID != "" {
+		org, orgErr = b.Store.GetAuth(enterpriseID)
+		if orgErr == nil && org.UninstalledAt == 0 {
+			b.addWorkspace(&org, teamID)
+			return org, nil
+		}
+		if orgErr != nil && orgErr != ErrNotFound {
+			return org, orgErr
+		}
+	}
+
+	rec, err := b.Store.GetAuth(teamID)
+	if err == ErrNotFound && orgErr == nil {
+		// only a tombstoned org-wide install exists
+		return org, nil
+	}
+	return rec, err
+}
+
+// addWorkspace records that an org-wide install has served a workspace, so
+// that the workspace's data can be found when the install is purged.
+func (b *SlackBot) addWorkspace(rec *AuthRecord, teamID string) {
+	if teamID == "" {
+		return
+	}
+	for _, w := range rec.Workspaces {
+		if w == teamID {
+			return
+		}
+	}
+
+	rec.Workspaces = append(rec.Workspaces, teamID)
+	err := b.Store.PutAuth(*rec)
+	if err != nil {
+		fmt.Println("WARN: unable to record workspace for org-wide install:", err)
+	}
+}
+
+// FindScoreTeamInstall returns the enterprise and workspace of an active
+// install that keeps its scores under team, a namespace returned by
+// ScoreTeam, ready to be passed to FindInstall or RetrieveTokensFor. It
+// returns ErrNotFound if no install uses the namespace. It reads every install
+// record so should only be used by maintenance tasks.
+func (b *SlackBot) FindScoreTeamInstall(team string) (enterpriseID, teamID string, err error) {
+	recs, err := b.Store.ListAuth()
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, rec := range recs {
+		if rec.UninstalledAt != 0 {
+			continue
+		}
+		for _, ns := range namespaces(rec) {
+			if ns == team {
+				return rec.EnterpriseID, rec.TeamID, nil
+			}
+		}
+	}
+	return "", "", ErrNotFound
+}
+
+// ScoreTeam returns the namespace under which scores for a workspace are
+// kept. Workspaces in a Grid org share the org's namespace when
+// ShareEnterpriseScores is set.
+func (b *SlackBot) ScoreTeam(enterpriseID, teamID string) string {
+	if b.ShareEnterpriseScores && enterpriseID != "" {
+		return enterpriseID
+	}
+	return teamID
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestEnterpriseID(t *testing.T) {
+	payload := url.Values{"payload": {`{"team": {"id": "T123", "enterprise_id": "E789"}}`}}
+
+	testCases := []struct {
+		name string
+		body string
+		id   string
+	}{
+		{name: "event callback", body: `{"team_id": "T123", "enterprise_id": "E123"}`, id: "E123"},
+		{name: "event callback outside grid", body: `{"team_id": "T123"}`, id: ""},
+		{name: "slash command", body: "team_id=T123&enterprise_id=E456", id: "E456"},
+		{name: "interactive payload", body: payload.Encode(), id: "E789"},
+		{name: "garbage", body: "%%%", id: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := EnterpriseID(events.APIGatewayProxyRequest{Body: tc.body})
+			if id != tc.id {
+				t.Errorf("should return %q, got %q", tc.id, id)
+			}
+		})
+	}
+}
+
+func TestFindInstall(t *testing.T) {
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s}
+
+	s.PutAuth(AuthRecord{UID: "T1", BotAccessToken: "xoxb-team"})
+	s.PutAuth(AuthRecord{UID: "E1", BotAccessToken: "xoxb-org", OrgInstall: true})
+
+	testCases := []struct {
+		name       string
+		enterprise string
+		team       string
+		token      string
+	}{
+		{name: "team install", team: "T1", token: "xoxb-team"},
+		{name: "org install preferred", enterprise: "E1", team: "T1", token: "xoxb-org"},
+		{name: "org install for workspace without its own", enterprise: "E1", team: "T2", token: "xoxb-org"},
+		{name: "team fallback", enterprise: "E2", team: "T1", token: "xoxb-team"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, _, _, err := b.RetrieveTokensFor(tc.enterprise, tc.team)
+			if err != nil || token != tc.token {
+				t.Errorf("should return %s, got %s (%v)", tc.token, token, err)
+			}
+		})
+	}
+
+	if _, err := b.FindInstall("E2", "T2"); err != ErrNotFound {
+		t.Error("should return ErrNotFound without an install, got:", err)
+	}
+
+	// a tombstoned org install gives way to the workspace's own install
+	b.Uninstall("E1", "T2", "test")
+	if token, _, _, _ := b.RetrieveTokensFor("E1", "T1"); token != "xoxb-team" {
+		t.Error("should fall back to the team install, got:", token)
+	}
+}
+
+func TestNewAuthRecordOrgInstall(t *testing.T) {
+	ar := OAuthResponse{AccessToken: "xoxb-1", IsEnterpriseInstall: true}
+	ar.Enterprise = &struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{ID: "E1", Name: "Org"}
+
+	rec := NewAuthRecord(ar, time.Unix(0, 0))
+	if rec.UID != "E1" || !rec.OrgInstall || rec.EnterpriseID != "E1" {
+		t.Errorf("should key org installs on the enterprise, got %+v", rec)
+	}
+}
+
+func TestScoreTeam(t *testing.T) {
+	b := &SlackBot{}
+	if team := b.ScoreTeam("E1", "T1"); team != "T1" {
+		t.Error("should keep scores per workspace by default, got:", team)
+	}
+
+	b.ShareEnterpriseScores = true
+	if team := b.ScoreTeam("E1", "T1"); team != "E1" {
+		t.Error("should share scores across the org, got:", team)
+	}
+	if team := b.ScoreTeam("", "T1"); team != "T1" {
+		t.Error("should keep scores per workspace outside a grid org, got:", team)
+	}
+}
+
+func TestFindScoreTeamInstall(t *testing.T) {
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s}
+	s.PutAuth(AuthRecord{UID: "T1", TeamID: "T1"})
+	s.PutAuth(AuthRecord{UID: "T2", TeamID: "T2", EnterpriseID: "E1"})
+	s.PutAuth(AuthRecord{UID: "E2", EnterpriseID: "E2", OrgInstall: true, Workspaces: []string{"T3"}})
+	s.PutAuth(AuthRecord{UID: "T4", TeamID: "T4", UninstalledAt: 1531420618})
+
+	testCases := []struct {
+		team string
+		ent  string
+		ws   string
+		err  error
+	}{
+		{team: "T1", ws: "T1"},
+		{team: "E1", ent: "E1", ws: "T2"},
+		{team: "T3", ent: "E2"},
+		{team: "E2", ent: "E2"},
+		{team: "T4", err: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		ent, ws, err := b.FindScoreTeamInstall(tc.team)
+		if ent != tc.ent || ws != tc.ws || err != tc.err {
+			t.Errorf("%s: should find %q %q (%v), got %q %q (%v)", tc.team, tc.ent, tc.ws, tc.err, ent, ws, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxBodySize limits the size of request bodies accepted by HTTPHandler. Slack
+// payloads are small so anything larger is rejected.
+const maxBodySize = 1 << 20
+
+// HTTPHandler adapts an APIHandler so it can be served by net/http. Incoming
+// requests are converted into API Gateway proxy requests and the proxy response
+// is written back to the client.
+func HTTPHandler(h APIHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := NewProxyRequest(r)
+		if err != nil {
+			fmt.Println("WARN: unable to read request:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp, err := h(req)
+		if err != nil {
+			fmt.Println("ERROR: handler failed:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = WriteProxyResponse(w, resp)
+		if err != nil {
+			fmt.Println("WARN: unable to write response:", err)
+		}
+	})
+}
+
+// NewProxyRequest converts an HTTP request into the API Gateway proxy request
+// our handlers expect. Only the first value of each header and query string
+// parameter is kept, matching the behaviour of API Gateway.
+func NewProxyRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod:            r.Method,
+		Path:                  r.URL.Path,
+		Headers:               make(map[string]string, len(r.Header)),
+		QueryStringParameters: make(map[string]string),
+	}
+
+	for k := range r.Header {
+		req.Headers[k] = r.Header.Get(k)
+	}
+
+	// net/http keeps the host apart from the other headers, and handlers
+	// expect to be told the scheme as they are behind API Gateway
+	if r.Host != "" {
+		req.Headers["Host"] = r.Host
+	}
+	if _, ok := req.Headers["X-Forwarded-Proto"]; !ok {
+		req.Headers["X-Forwarded-Proto"] = "http"
+		if r.TLS != nil {
+			req.Headers["X-Forwarded-Proto"] = "https"
+		}
+	}
+
+	q := r.URL.Query()
+	for k := range q {
+		req.QueryStringParameters[k] = q.Get(k)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return req, err
+	}
+	req.Body = string(body)
+
+	return req, nil
+}
+
+// WriteProxyResponse writes an API Gateway proxy response to w.
+func WriteProxyResponse(w http.ResponseWriter, resp events.APIGatewayProxyResponse) error {
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	_, err := w.Write(body)
+	return err
+}
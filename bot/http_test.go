@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	var got events.APIGatewayProxyRequest
+	h := HTTPHandler(func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = req
+		resp := events.APIGatewayProxyResponse{
+			StatusCode: http.StatusAccepted,
+			Body:       "done",
+			Headers:    map[string]string{"Content-Type": "text"},
+		}
+		return resp, nil
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/event?code=abc", strings.NewReader("payload"))
+	r.Header.Set("X-Slack-Request-Timestamp", "1531420618")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got.HTTPMethod != http.MethodPost || got.Path != "/event" {
+		t.Errorf("should pass method and path, got %s %s", got.HTTPMethod, got.Path)
+	}
+	if got.Headers["X-Slack-Request-Timestamp"] != "1531420618" {
+		t.Error("should pass Slack headers using their canonical names")
+	}
+	if got.Headers["Host"] != "example.com" || got.Headers["X-Forwarded-Proto"] != "http" {
+		t.Errorf("should pass the host and scheme, got %q %q", got.Headers["Host"], got.Headers["X-Forwarded-Proto"])
+	}
+	if got.QueryStringParameters["code"] != "abc" {
+		t.Error("should pass query string parameters")
+	}
+	if got.Body != "payload" {
+		t.Error("should pass the request body")
+	}
+
+	if w.Code != http.StatusAccepted || w.Body.String() != "done" || w.Header().Get("Content-Type") != "text" {
+		t.Errorf("should write the proxy response, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Period is the span of time a leaderboard covers.
+type Period string
+
+// Periods for which scores are kept.
+const (
+	AllTime Period = "all"
+	Week    Period = "week"
+	Month   Period = "month"
+)
+
+// periods lists the periods, other than AllTime, that each award counts
+// towards.
+var periods = []Period{Week, Month}
+
+// ParsePeriod returns the Period with the given name.
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(strings.ToLower(s)); p {
+	case AllTime, Week, Month:
+		return p, nil
+	default:
+		return AllTime, errors.Errorf("unknown period '%s'", s)
+	}
+}
+
+// Board returns the name of the board holding a team's scores for the period
+// that includes t. All-time scores are held on a board named after the team.
+// Weeks are ISO weeks and, like months, are in UTC.
+func Board(team string, p Period, t time.Time) string {
+	t = t.UTC()
+	switch p {
+	case Week:
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%s@%d-W%02d", team, y, w)
+	case Month:
+		return fmt.Sprintf("%s@%s", team, t.Format("2006-01"))
+	default:
+		return team
+	}
+}
+
+// isPeriodBoard reports whether a board holds scores for a limited period.
+// Period boards end with the date the period starts, e.g. team@2018-W27.
+func isPeriodBoard(board string) bool {
+	i := strings.LastIndex(board, "@")
+	return i >= 0 && i+1 < len(board) && board[i+1] >= '0' && board[i+1] <= '9'
+}
+
+// Score is a user's score on a board.
+type Score struct {
+	User  string `json:"user"`
+	Score int    `json:"score"`
+}
+
+// sortScores orders scores from highest to lowest. Equal scores are ordered by
+// user so the order is stable.
+func sortScores(scores []Score) {
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].User < scores[j].User
+	})
+}
+
+// Standing is a user's position on a leaderboard. Users with the same score
+// share a rank.
+type Standing struct {
+	User  string
+	Score int
+	Rank  int
+}
+
+// Leaderboard is the top of a board along with the standing of the user who
+// asked for it.
+type Leaderboard struct {
+	Period Period
+	Top    []Standing
+	Caller Standing
+}
+
+// Leaderboard returns the top n users in a team for the current period, along
+// with the standing of user.
+func (b *SlackBot) Leaderboard(team string, p Period, n int, user string) (Leaderboard, error) {
+	lb := Leaderboard{Period: p}
+	board := Board(team, p, b.now())
+
+	top, err := b.Store.TopScores(board, n)
+	if err != nil {
+		return lb, errors.Wrap(err, "unable to get top scores")
+	}
+
+	for i, s := range top {
+		rank := i + 1
+		if i > 0 && s.Score == top[i-1].Score {
+			rank = lb.Top[i-1].Rank
+		}
+		lb.Top = append(lb.Top, Standing{User: s.User, Score: s.Score, Rank: rank})
+	}
+
+	score, rank, err := b.Store.Rank(board, user)
+	if err != nil {
+		return lb, errors.Wrap(err, "unable to get rank")
+	}
+	lb.Caller = Standing{User: user, Score: score, Rank: rank}
+
+	return lb, nil
+}
+
+// UserSummary describes how a user is doing: their all-time score and rank,
+// the points they've received this week and their most recent awards.
+type UserSummary struct {
+	User   string
+	Score  int
+	Rank   int
+	Week   int
+	Recent []Award
+}
+
+// UserSummary returns a summary of a user's score in a team, including up to
+// recent of the awards they've received from other users. Things are
+// summarised in the same way using their ThingsTeam.
+func (b *SlackBot) UserSummary(team, user string, recent int) (UserSummary, error) {
+	us := UserSummary{User: user}
+
+	var err error
+	us.Score, us.Rank, err = b.Store.Rank(team, user)
+	if err != nil {
+		return us, errors.Wrap(err, "unable to get rank")
+	}
+
+	us.Week, err = b.Store.GetScore(Board(team, Week, b.now()), user)
+	if err != nil {
+		return us, errors.Wrap(err, "unable to get score for the week")
+	}
+
+	// fetch one more in case an opening balance, which has no giver, is among them
+	awards, err := b.Store.ListAwards(team, user, recent+1)
+	if err != nil {
+		return us, errors.Wrap(err, "unable to list awards")
+	}
+	for _, a := range awards {
+		if a.Giver != "" && len(us.Recent) < recent {
+			us.Recent = append(us.Recent, a)
+		}
+	}
+
+	return us, nil
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoard(t *testing.T) {
+	at := time.Date(2018, 12, 31, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		period Period
+		want   string
+	}{
+		{period: AllTime, want: "T123"},
+		{period: Week, want: "T123@2019-W01"},
+		{period: Month, want: "T123@2018-12"},
+	}
+
+	for _, tc := range testCases {
+		if got := Board("T123", tc.period, at); got != tc.want {
+			t.Errorf("should name the %s board %s, got %s", tc.period, tc.want, got)
+		}
+	}
+
+	if _, err := ParsePeriod("year"); err == nil {
+		t.Error("should reject unknown periods")
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	now := time.Date(2018, 7, 4, 9, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, Clock: func() time.Time { return now }}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U9", Amount: 1, At: now.AddDate(0, 0, -7)})
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U9", Amount: 1})
+	b.RecordAward(Award{Team: "T123", Receiver: "U2", Giver: "U9", Amount: 1})
+	b.RecordAward(Award{Team: "T123", Receiver: "U3", Giver: "U9", Amount: 2})
+
+	lb, err := b.Leaderboard("T123", Week, 2, "U2")
+	if err != nil {
+		t.Fatal("should return the leaderboard:", err)
+	}
+	want := []Standing{{User: "U3", Score: 2, Rank: 1}, {User: "U1", Score: 1, Rank: 2}}
+	if len(lb.Top) != 2 || lb.Top[0] != want[0] || lb.Top[1] != want[1] {
+		t.Errorf("should return the top of this week's board, got %+v", lb.Top)
+	}
+	if lb.Caller != (Standing{User: "U2", Score: 1, Rank: 2}) {
+		t.Errorf("should share ranks between equal scores, got %+v", lb.Caller)
+	}
+
+	lb, _ = b.Leaderboard("T123", AllTime, 10, "U1")
+	if lb.Top[0] != (Standing{User: "U1", Score: 2, Rank: 1}) || lb.Top[1].Rank != 1 {
+		t.Errorf("should return the all-time board, got %+v", lb.Top)
+	}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Amount: 4, Reason: OpeningBalance})
+	us, err := b.UserSummary("T123", "U1", 1)
+	if err != nil {
+		t.Fatal("should return the summary:", err)
+	}
+	if us.Score != 6 || us.Rank != 1 || us.Week != 1 {
+		t.Errorf("should summarise the user's scores, got %+v", us)
+	}
+	if len(us.Recent) != 1 || us.Recent[0].Giver != "U9" {
+		t.Errorf("should skip adjustments in recent awards, got %+v", us.Recent)
+	}
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// OpeningBalance is the reason recorded on awards written by RebuildScores to
+// account for points given before the ledger existed.
+const OpeningBalance = "opening balance"
+
+// RebuildReport summarises a call to RebuildScores.
+type RebuildReport struct {
+	Scores    int // scores checked
+	Corrected int // scores that didn't match the ledger
+	Seeded    int // opening balances added to the ledger
+}
+
+// RecordAward appends an award to the ledger and then applies it to the
+// receiver's score, which is kept as a running total of the ledger. Awards
+// from users also count towards the receiver's score for the current week and
+// month. It returns the new all-time score.
+//
+// The ledger is written first so that it stays the source of truth. If a
+// score can't be updated, RebuildScores will correct it.
+func (b *SlackBot) RecordAward(a Award) (int, error) {
+	if a.At.IsZero() {
+		a.At = b.now().UTC()
+	}
+
+	err := b.Store.AddAward(a)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to record award")
+	}
+
+	score, err := b.Store.IncrementScore(a.Team, a.Receiver, a.Amount)
+	if err != nil {
+		return score, errors.Wrap(err, "unable to update score")
+	}
+
+	for _, board := range periodBoards(a) {
+		_, err := b.Store.IncrementScore(board, a.Receiver, a.Amount)
+		if err != nil {
+			fmt.Printf("WARN: unable to update score on board '%s': %v\n", board, err)
+		}
+	}
+
+	return score, nil
+}
+
+// GivenFor returns the net points a giver has given a receiver for a message
+// with the reason given, so that awards can be taken back. If reason is empty,
+// points given for any reason count.
+func (b *SlackBot) GivenFor(team, giver, receiver, messageTS, reason string) (int, error) {
+	awards, err := b.Store.ListMessageAwards(team, receiver, messageTS)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list awards for message")
+	}
+
+	given := 0
+	for _, a := range awards {
+		if a.Giver == giver && (reason == "" || a.Reason == reason) {
+			given += a.Amount
+		}
+	}
+	return given, nil
+}
+
+// periodBoards returns the boards, other than the all-time board, that an
+// award counts towards. Adjustments made by BuddyBot only count all-time.
+func periodBoards(a Award) []string {
+	if a.Giver == "" {
+		return nil
+	}
+
+	var boards []string
+	for _, p := range periods {
+		boards = append(boards, Board(a.Team, p, a.At))
+	}
+	return boards
+}
+
+// RebuildScores recalculates every score from the ledger and corrects any that
+// differ. Scores given before the ledger existed aren't in it, so the first
+// rebuild should be made with seed set. This adds an opening balance to the
+// ledger for any difference rather than discarding those points.
+//
+// Scores for a limited period are always corrected, and every score is
+// rewritten so that scores kept before leaderboards existed are indexed. If
+// dryRun is set, differences are reported but nothing is changed.
+func (b *SlackBot) RebuildScores(seed, dryRun bool) (RebuildReport, error) {
+	report := RebuildReport{}
+
+	totals := make(map[string]int)
+	err := b.Store.ForEachAward(func(a Award) error {
+		totals[scoreKey(a.Team, a.Receiver)] += a.Amount
+		for _, board := range periodBoards(a) {
+			totals[scoreKey(board, a.Receiver)] += a.Amount
+		}
+		return nil
+	})
+	if err != nil {
+		return report, errors.Wrap(err, "unable to read ledger")
+	}
+
+	scores := make(map[string]int)
+	err = b.Store.ForEachScore(func(team, user string, score int) error {
+		scores[scoreKey(team, user)] = score
+		return nil
+	})
+	if err != nil {
+		return report, errors.Wrap(err, "unable to read scores")
+	}
+
+	// users may have a score without any awards, or awards without a score
+	for k := range totals {
+		if _, ok := scores[k]; !ok {
+			scores[k] = 0
+		}
+	}
+
+	for k, score := range scores {
+		report.Scores++
+
+		team, user := splitScoreKey(k)
+		want := totals[k]
+
+		switch {
+		case score == want:
+		case seed && !isPeriodBoard(team):
+			report.Seeded++
+			if !dryRun {
+				a := Award{
+					Team:     team,
+					Receiver: user,
+					Amount:   score - want,
+					Reason:   OpeningBalance,
+					At:       b.now().UTC(),
+				}
+				err := b.Store.AddAward(a)
+				if err != nil {
+					return report, errors.Wrapf(err, "unable to seed ledger for '%s'", k)
+				}
+			}
+			want = score
+		default:
+			report.Corrected++
+		}
+
+		if dryRun {
+			continue
+		}
+
+		err := b.Store.SetScore(team, user, want)
+		if err != nil {
+			return report, errors.Wrapf(err, "unable to correct score for '%s'", k)
+		}
+	}
+
+	return report, nil
+}
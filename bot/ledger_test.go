@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordAward(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, Clock: func() time.Time { return now }}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", Amount: 1})
+	score, err := b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U3", Amount: -1, Reason: "for breaking the build"})
+	if err != nil || score != 0 {
+		t.Errorf("should return the new score, got %d (%v)", score, err)
+	}
+
+	awards, _ := s.ListAwards("T123", "U1", 10)
+	if len(awards) != 2 {
+		t.Fatal("should add each award to the ledger, got:", len(awards))
+	}
+	if !awards[0].At.Equal(now) {
+		t.Error("should timestamp awards, got:", awards[0].At)
+	}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", Amount: 2})
+	for _, board := range []string{Board("T123", Week, now), Board("T123", Month, now)} {
+		if score, _ := s.GetScore(board, "U1"); score != 2 {
+			t.Errorf("should update the score on %s, got %d", board, score)
+		}
+	}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Amount: 5, Reason: OpeningBalance})
+	if score, _ := s.GetScore(Board("T123", Week, now), "U1"); score != 2 {
+		t.Error("should only count adjustments all-time, got:", score)
+	}
+}
+
+func TestGivenFor(t *testing.T) {
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", MessageTS: "1.1", Amount: 2, Reason: ":taco:"})
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", MessageTS: "1.1", Amount: 1, Reason: ":+1:"})
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U3", MessageTS: "1.1", Amount: 2, Reason: ":taco:"})
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", MessageTS: "2.2", Amount: 2, Reason: ":taco:"})
+
+	given, err := b.GivenFor("T123", "U2", "U1", "1.1", ":taco:")
+	if err != nil || given != 2 {
+		t.Errorf("should return the points given for the message, got %d (%v)", given, err)
+	}
+
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U2", MessageTS: "1.1", Amount: -2, Reason: ":taco:"})
+	if given, _ := b.GivenFor("T123", "U2", "U1", "1.1", ":taco:"); given != 0 {
+		t.Error("should net off points taken back, got:", given)
+	}
+}
+
+func TestRebuildScores(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, Clock: func() time.Time { return now }}
+
+	// U1 has points from before the ledger, U2's score is out of step
+	s.IncrementScore("T123", "U1", 5)
+	b.RecordAward(Award{Team: "T123", Receiver: "U1", Giver: "U3", Amount: 1})
+	b.RecordAward(Award{Team: "T123", Receiver: "U2", Giver: "U3", Amount: 2})
+	s.IncrementScore("T123", "U2", 3)
+
+	// U1 and U2 each have all-time, weekly and monthly scores
+	r, err := b.RebuildScores(true, true)
+	if err != nil || r.Scores != 6 || r.Seeded != 2 {
+		t.Errorf("should report the balances it would seed, got %+v (%v)", r, err)
+	}
+	if score, _ := s.GetScore("T123", "U1"); score != 6 {
+		t.Error("should not change anything on a dry run, got:", score)
+	}
+
+	r, err = b.RebuildScores(true, false)
+	if err != nil || r.Seeded != 2 {
+		t.Errorf("should seed opening balances, got %+v (%v)", r, err)
+	}
+	awards, _ := s.ListAwards("T123", "U1", 10)
+	if len(awards) != 2 || awards[0].Reason != OpeningBalance || awards[0].Amount != 5 {
+		t.Errorf("should add the difference to the ledger, got %+v", awards)
+	}
+
+	// once seeded, the ledger wins
+	s.IncrementScore("T123", "U1", 10)
+	r, err = b.RebuildScores(false, false)
+	if err != nil || r.Corrected != 1 || r.Seeded != 0 {
+		t.Errorf("should correct the score, got %+v (%v)", r, err)
+	}
+	for user, want := range map[string]int{"U1": 6, "U2": 5} {
+		if score, _ := s.GetScore("T123", user); score != want {
+			t.Errorf("should rebuild the score for %s, got %d", user, score)
+		}
+	}
+	if score, _ := s.GetScore(Board("T123", Week, now), "U1"); score != 1 {
+		t.Error("should not count opening balances towards the week, got:", score)
+	}
+}
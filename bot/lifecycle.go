@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack/slackevents"
+	"github.com/pkg/errors"
+)
+
+// DefaultUninstallGracePeriod is how long data for an uninstalled workspace is
+// kept before it may be purged. Reinstalling inside this period restores it.
+const DefaultUninstallGracePeriod = 30 * 24 * time.Hour
+
+// TokensRevoked is the Events API type sent when tokens are revoked.
+const TokensRevoked = "tokens_revoked"
+
+// ErrUninstalled is returned when tokens are requested for a workspace that
+// has uninstalled BuddyBot.
+var ErrUninstalled = errors.New("workspace has uninstalled BuddyBot")
+
+// TokensRevokedEvent is sent when the tokens for one or more users, or for the
+// bot itself, are revoked.
+type TokensRevokedEvent struct {
+	Type   string `json:"type"`
+	Tokens struct {
+		OAuth []string `json:"oauth"`
+		Bot   []string `json:"bot"`
+	} `json:"tokens"`
+}
+
+func init() {
+	// slackevents doesn't yet know about tokens_revoked
+	slackevents.EventsAPIInnerEventMapping[TokensRevoked] = TokensRevokedEvent{}
+}
+
+// Uninstall tombstones the install record covering a workspace. Tokens are
+// removed immediately, while scores are kept until the grace period has passed
+// so that a reinstall can pick up where the workspace left off.
+func (b *SlackBot) Uninstall(enterpriseID, teamID, reason string) error {
+	rec, err := b.FindInstall(enterpriseID, teamID)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if rec.UninstalledAt != 0 {
+		return nil
+	}
+
+	rec.AccessToken = ""
+	rec.RefreshToken = ""
+	rec.BotAccessToken = ""
+	rec.BotRefreshToken = ""
+	rec.UninstalledAt = b.now().Unix()
+
+	err = b.Store.PutAuth(rec)
+	if err != nil {
+		return errors.Wrap(err, "unable to tombstone install record")
+	}
+
+	audit("uninstalled", rec.UID, reason)
+	return nil
+}
+
+// RevokeTokens handles a tokens_revoked event. Revoking the bot token has the
+// same effect as uninstalling, while revoking a user token only removes that
+// token from the install record.
+func (b *SlackBot) RevokeTokens(enterpriseID, teamID string, ev *TokensRevokedEvent) error {
+	rec, err := b.FindInstall(enterpriseID, teamID)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, u := range ev.Tokens.Bot {
+		if u == rec.BotUserID {
+			return b.Uninstall(enterpriseID, teamID, "bot token revoked")
+		}
+	}
+
+	for _, u := range ev.Tokens.OAuth {
+		if u == rec.UserID && rec.AccessToken != "" {
+			rec.AccessToken = ""
+			rec.RefreshToken = ""
+
+			err = b.Store.PutAuth(rec)
+			if err != nil {
+				return errors.Wrap(err, "unable to remove revoked token")
+			}
+
+			audit("user token revoked", rec.UID, u)
+		}
+	}
+
+	return nil
+}
+
+// PurgeUninstalled deletes the install record, scores, awards and settings of
+// every workspace that uninstalled BuddyBot more than the grace period ago. It
+// returns the number of workspaces purged.
+func (b *SlackBot) PurgeUninstalled() (int, error) {
+	recs, err := b.Store.ListAuth()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := b.now().Add(-b.uninstallGracePeriod()).Unix()
+
+	// data may be kept under the enterprise or a workspace covered by an
+	// org-wide install, so only purge what no remaining install still uses
+	var purge []AuthRecord
+	inUse := make(map[string]bool)
+	for _, rec := range recs {
+		if rec.UninstalledAt == 0 || rec.UninstalledAt > cutoff {
+			for _, ns := range namespaces(rec) {
+				inUse[ns] = true
+			}
+			continue
+		}
+		purge = append(purge, rec)
+	}
+
+	n := 0
+	for _, rec := range purge {
+		scores, awards := 0, 0
+		for _, ns := range namespaces(rec) {
+			if inUse[ns] {
+				continue
+			}
+
+			s, err := b.Store.DeleteScores(ns)
+			if err != nil {
+				return n, errors.Wrapf(err, "unable to purge scores for '%s'", rec.UID)
+			}
+			scores += s
+
+			a, err := b.Store.DeleteAwards(ns)
+			if err != nil {
+				return n, errors.Wrapf(err, "unable to purge awards for '%s'", rec.UID)
+			}
+			awards += a
+
+			err = b.Store.DeleteSettings(ns)
+			if err != nil {
+				return n, errors.Wrapf(err, "unable to purge settings for '%s'", rec.UID)
+			}
+		}
+
+		err = b.Store.DeleteAuth(rec.UID)
+		if err != nil {
+			return n, errors.Wrapf(err, "unable to purge install record for '%s'", rec.UID)
+		}
+
+		audit("purged", rec.UID, fmt.Sprintf("%d scores and %d awards removed", scores, awards))
+		n++
+	}
+
+	return n, nil
+}
+
+// namespaces returns every namespace an install may have kept scores, awards
+// and settings under. Which one was used depends on ShareEnterpriseScores, so
+// both the workspaces and the enterprise are included.
+func namespaces(rec AuthRecord) []string {
+	var ns []string
+	seen := make(map[string]bool)
+	for _, n := range append([]string{rec.TeamID, rec.EnterpriseID}, rec.Workspaces...) {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// uninstallGracePeriod returns how long to keep data for uninstalled workspaces.
+func (b *SlackBot) uninstallGracePeriod() time.Duration {
+	if b.UninstallGracePeriod <= 0 {
+		return DefaultUninstallGracePeriod
+	}
+	return b.UninstallGracePeriod
+}
+
+// audit logs a change to a workspace's installation.
+func audit(action, uid, detail string) {
+	fmt.Printf("AUDIT: %s uid=%s time=%s detail=%q\n", action, uid, time.Now().UTC().Format(time.RFC3339), detail)
+}
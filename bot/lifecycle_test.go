@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUninstall(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, Clock: func() time.Time { return now }}
+
+	install := AuthRecord{UID: "T123", TeamID: "T123", UserID: "U1", BotUserID: "B1", AccessToken: "xoxp-1", BotAccessToken: "xoxb-1"}
+	s.PutAuth(install)
+	s.IncrementScore("T123", "U2", 5)
+
+	if err := b.Uninstall("", "T123", "test"); err != nil {
+		t.Fatal("should uninstall:", err)
+	}
+
+	rec, _ := s.GetAuth("T123")
+	if rec.UninstalledAt != now.Unix() || rec.AccessToken != "" || rec.BotAccessToken != "" {
+		t.Errorf("should tombstone the record and remove tokens, got %+v", rec)
+	}
+
+	if _, _, _, err := b.RetrieveTokens("T123"); err != ErrUninstalled {
+		t.Error("should refuse tokens for an uninstalled workspace, got:", err)
+	}
+
+	// nothing is purged inside the grace period
+	now = now.Add(DefaultUninstallGracePeriod - time.Hour)
+	if n, err := b.PurgeUninstalled(); err != nil || n != 0 {
+		t.Errorf("should not purge inside the grace period, got %d (%v)", n, err)
+	}
+
+	// reinstalling restores the workspace
+	s.PutAuth(install)
+	if _, _, _, err := b.RetrieveTokens("T123"); err != nil {
+		t.Error("should return tokens after a reinstall:", err)
+	}
+	if score, _ := s.GetScore("T123", "U2"); score != 5 {
+		t.Error("should keep scores after a reinstall, got:", score)
+	}
+
+	// purge after the grace period
+	b.Uninstall("", "T123", "test")
+	now = now.Add(DefaultUninstallGracePeriod + time.Hour)
+	if n, err := b.PurgeUninstalled(); err != nil || n != 1 {
+		t.Errorf("should purge after the grace period, got %d (%v)", n, err)
+	}
+	if _, err := s.GetAuth("T123"); err != ErrNotFound {
+		t.Error("should delete the install record, got:", err)
+	}
+	if score, _ := s.GetScore("T123", "U2"); score != 0 {
+		t.Error("should delete scores, got:", score)
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s}
+	s.PutAuth(AuthRecord{UID: "T123", UserID: "U1", BotUserID: "B1", AccessToken: "xoxp-1", BotAccessToken: "xoxb-1"})
+
+	ev := &TokensRevokedEvent{}
+	ev.Tokens.OAuth = []string{"U1"}
+	if err := b.RevokeTokens("", "T123", ev); err != nil {
+		t.Fatal("should revoke the user token:", err)
+	}
+
+	rec, _ := s.GetAuth("T123")
+	if rec.AccessToken != "" || rec.BotAccessToken != "xoxb-1" || rec.UninstalledAt != 0 {
+		t.Errorf("should only remove the user token, got %+v", rec)
+	}
+
+	ev = &TokensRevokedEvent{}
+	ev.Tokens.Bot = []string{"B1"}
+	b.RevokeTokens("", "T123", ev)
+
+	rec, _ = s.GetAuth("T123")
+	if rec.BotAccessToken != "" || rec.UninstalledAt == 0 {
+		t.Errorf("should uninstall when the bot token is revoked, got %+v", rec)
+	}
+}
+
+func TestPurgeUninstalledNamespaces(t *testing.T) {
+	testCases := []struct {
+		name    string
+		install AuthRecord
+		share   bool
+	}{
+		{name: "workspace install", install: AuthRecord{UID: "T1", TeamID: "T1", EnterpriseID: "E1"}},
+		{name: "workspace install sharing scores", install: AuthRecord{UID: "T1", TeamID: "T1", EnterpriseID: "E1"}, share: true},
+		{name: "org install", install: AuthRecord{UID: "E1", EnterpriseID: "E1", OrgInstall: true}},
+		{name: "org install sharing scores", install: AuthRecord{UID: "E1", EnterpriseID: "E1", OrgInstall: true}, share: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Unix(1531420618, 0)
+			s := NewMemoryStore()
+			b := &SlackBot{Store: s, ShareEnterpriseScores: tc.share, Clock: func() time.Time { return now }}
+			s.PutAuth(tc.install)
+
+			// another workspace keeps its own install
+			s.PutAuth(AuthRecord{UID: "T9", TeamID: "T9"})
+			s.IncrementScore("T9", "U2", 1)
+
+			teams := []string{"T1"}
+			if tc.install.OrgInstall {
+				teams = append(teams, "T2")
+			}
+			for _, team := range teams {
+				if _, err := b.FindInstall("E1", team); err != nil {
+					t.Fatal("should find the install:", err)
+				}
+				ns := b.ScoreTeam("E1", team)
+				s.IncrementScore(ns, "U2", 5)
+				s.IncrementScore(ThingsTeam(ns), "kubernetes", 1)
+				s.AddAward(Award{Team: ns, Receiver: "U2", Giver: "U1", Amount: 5, At: now})
+				s.PutSettings(ns, Settings{Cooldown: time.Minute})
+			}
+
+			b.Uninstall("E1", "T1", "test")
+			now = now.Add(DefaultUninstallGracePeriod + time.Hour)
+			if n, err := b.PurgeUninstalled(); err != nil || n != 1 {
+				t.Fatalf("should purge the install, got %d (%v)", n, err)
+			}
+
+			for _, team := range teams {
+				ns := b.ScoreTeam("E1", team)
+				if score, _ := s.GetScore(ns, "U2"); score != 0 {
+					t.Errorf("should delete scores in %s, got: %d", ns, score)
+				}
+				if score, _ := s.GetScore(ThingsTeam(ns), "kubernetes"); score != 0 {
+					t.Errorf("should delete things in %s, got: %d", ns, score)
+				}
+				if awards, _ := s.ListAwards(ns, "U2", 10); len(awards) != 0 {
+					t.Errorf("should delete awards in %s, got: %d", ns, len(awards))
+				}
+				if settings, _ := s.GetSettings(ns); settings.Cooldown != 0 {
+					t.Errorf("should delete settings in %s, got: %+v", ns, settings)
+				}
+			}
+			if score, _ := s.GetScore("T9", "U2"); score != 1 {
+				t.Error("should keep other workspaces' scores, got:", score)
+			}
+		})
+	}
+}
+
+func TestPurgeUninstalledSharedEnterprise(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	s := NewMemoryStore()
+	b := &SlackBot{Store: s, ShareEnterpriseScores: true, Clock: func() time.Time { return now }}
+	s.PutAuth(AuthRecord{UID: "T1", TeamID: "T1", EnterpriseID: "E1"})
+	s.PutAuth(AuthRecord{UID: "T2", TeamID: "T2", EnterpriseID: "E1"})
+	s.IncrementScore("E1", "U2", 5)
+
+	b.Uninstall("E1", "T1", "test")
+	now = now.Add(DefaultUninstallGracePeriod + time.Hour)
+	if n, err := b.PurgeUninstalled(); err != nil || n != 1 {
+		t.Fatalf("should purge the install, got %d (%v)", n, err)
+	}
+	if score, _ := s.GetScore("E1", "U2"); score != 5 {
+		t.Error("should keep scores shared with workspaces still installed, got:", score)
+	}
+}
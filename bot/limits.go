@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOverLimit is returned by a Store when an award would take a giver over
+// their limits.
+var ErrOverLimit = errors.New("over the award limit")
+
+// Limits restrict how many awards a user may give. A zero value for any limit
+// means there is no limit. Daily limits count the awards made in the
+// AllowanceWindow starting with the giver's first award in it, so they can't
+// be used twice over either side of midnight.
+type Limits struct {
+	PerDay      int           // awards a giver may make each day
+	PerReceiver int           // awards a giver may make to the same receiver each day
+	Cooldown    time.Duration // time a giver must wait between awards
+}
+
+// AllowanceWindow is how long a giver's daily allowance lasts, from the first
+// award made with it.
+const AllowanceWindow = 24 * time.Hour
+
+// none reports whether no limits are set.
+func (l Limits) none() bool {
+	return l.PerDay <= 0 && l.PerReceiver <= 0 && l.Cooldown <= 0
+}
+
+// Usage is how much of their allowance a giver has used in a day.
+type Usage struct {
+	Given   int       `json:"given"`   // awards made that day
+	GivenTo int       `json:"givenTo"` // awards made to the receiver that day
+	Last    time.Time `json:"last"`    // time of the most recent award
+	Start   time.Time `json:"start"`   // time of the first award that day
+}
+
+// LimitError explains which limit stopped an award. Name and Data identify
+// the message explaining it, so that it can be sent in the giver's locale.
+type LimitError struct {
+	Message string
+	Name    string
+	Data    MessageData
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// Text explains the limit using the workspace's messages, in the first of the
+// locales given that there is a version of the message for.
+func (e *LimitError) Text(s Settings, locales ...string) string {
+	return s.Message(e.Name, e.Data, locales...)
+}
+
+// UseAllowance checks whether giver may make an award to receiver without
+// exceeding the limits, and records it against their allowance if so. The
+// check and the update are made atomically by the Store. It returns a
+// *LimitError explaining the limit if the award isn't allowed.
+func (b *SlackBot) UseAllowance(team, giver, receiver string, l Limits) error {
+	if l.none() {
+		return nil
+	}
+
+	now := b.now()
+	u, err := b.Store.UseAllowance(team, giver, receiver, now, l)
+	if err == ErrOverLimit {
+		name, data := limitMessage(receiver, now, u, l)
+		return &LimitError{Message: Settings{}.Message(name, data), Name: name, Data: data}
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to check allowance")
+	}
+	return nil
+}
+
+// limitMessage returns the name of the message explaining to a giver which
+// limit they have reached, and the data for it.
+func limitMessage(receiver string, now time.Time, u Usage, l Limits) (string, MessageData) {
+	switch {
+	case l.Cooldown > 0 && now.Sub(u.Last) < l.Cooldown:
+		wait := (l.Cooldown - now.Sub(u.Last)).Round(time.Second)
+		return "limitCooldown", MessageData{Wait: wait}
+	case l.PerReceiver > 0 && u.GivenTo >= l.PerReceiver:
+		return "limitReceiver", MessageData{Subject: Mention(receiver), Wait: resetIn(now, u)}
+	default:
+		return "limitDaily", MessageData{Limit: l.PerDay, Wait: resetIn(now, u)}
+	}
+}
+
+// resetIn returns how long until a giver's allowance starts afresh.
+func resetIn(now time.Time, u Usage) time.Duration {
+	return u.Start.Add(AllowanceWindow).Sub(now).Round(time.Minute)
+}
+
+// allowed reports whether an award may be made given the usage so far.
+func allowed(u Usage, now time.Time, l Limits) bool {
+	if l.PerDay > 0 && u.Given >= l.PerDay {
+		return false
+	}
+	if l.PerReceiver > 0 && u.GivenTo >= l.PerReceiver {
+		return false
+	}
+	if l.Cooldown > 0 && now.Sub(u.Last) < l.Cooldown {
+		return false
+	}
+	return true
+}
+
+// allowance is the usage a Store keeps for a giver.
+type allowance struct {
+	Given     int            `json:"given"`
+	Receivers map[string]int `json:"receivers"`
+	Last      time.Time      `json:"last"`
+	Start     time.Time      `json:"start"`
+}
+
+// current returns the allowance in use at the time given, which starts
+// afresh once the window has passed. The time of the last award is kept so
+// that the cooldown still applies.
+func (a allowance) current(at time.Time) allowance {
+	if at.Sub(a.Start) >= AllowanceWindow {
+		return allowance{Last: a.Last}
+	}
+	return a
+}
+
+// usage returns the allowance used, including the awards made to receiver.
+func (a allowance) usage(receiver string) Usage {
+	return Usage{Given: a.Given, GivenTo: a.Receivers[receiver], Last: a.Last, Start: a.Start}
+}
+
+// use records an award to receiver at the time given.
+func (a *allowance) use(receiver string, at time.Time) {
+	if a.Receivers == nil {
+		a.Receivers = make(map[string]int)
+	}
+	if a.Start.IsZero() {
+		a.Start = at
+	}
+	a.Given++
+	a.Receivers[receiver]++
+	a.Last = at
+}
+
+// allowanceKey returns the key under which a giver's usage is stored.
+func allowanceKey(team, giver string) string {
+	return scoreKey(team, giver)
+}
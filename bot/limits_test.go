@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseAllowance(t *testing.T) {
+	now := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	b := &SlackBot{Store: NewMemoryStore(), Clock: func() time.Time { return now }}
+
+	for i := 0; i < 5; i++ {
+		if err := b.UseAllowance("T123", "U1", "U2", Limits{}); err != nil {
+			t.Fatal("should allow any number of awards without limits:", err)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		limits Limits
+		want   string
+	}{
+		{name: "cooldown", limits: Limits{Cooldown: time.Minute}, want: "in 1m0s"},
+		{name: "per receiver", limits: Limits{PerReceiver: 1}, want: "all the points you can for now"},
+		{name: "per day", limits: Limits{PerDay: 1}, want: "all 1 of your points. Your allowance resets in 24h0m0s"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			giver := string(rune('A' + i))
+			if err := b.UseAllowance("T123", giver, "U2", tc.limits); err != nil {
+				t.Fatal("should allow the first award:", err)
+			}
+
+			err := b.UseAllowance("T123", giver, "U2", tc.limits)
+			le, ok := err.(*LimitError)
+			if !ok {
+				t.Fatal("should return a LimitError, got:", err)
+			}
+			if !strings.Contains(le.Error(), tc.want) {
+				t.Errorf("should explain the limit with %q, got %q", tc.want, le.Error())
+			}
+		})
+	}
+}
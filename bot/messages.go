@@ -0,0 +1,328 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLocale is the locale used when neither the user nor the workspace
+// has one BuddyBot has messages for.
+const DefaultLocale = "en"
+
+// catalog maps each locale onto its parsed messages. It is loaded the first
+// time a message is needed, see messageCatalog.
+var (
+	catalog     map[string]map[string]*template.Template
+	catalogOnce sync.Once
+)
+
+// MessageData is the data available to message templates. Only the fields
+// relevant to a message are set.
+type MessageData struct {
+	User    string        // mention of the user the message is about
+	Subject string        // mention of the user or thing the message is about
+	Score   int           // the subject's new score
+	Reason  string        // the reason given for a vote
+	Limit   int           // the limit that was reached
+	Wait    time.Duration // how long until the limit allows another award
+	Text    string        // the text of a flagged message
+}
+
+// sampleMessageData is used to check that templates can be executed.
+var sampleMessageData = MessageData{
+	User:    "<@U0123ABCD>",
+	Subject: "*kubernetes*",
+	Score:   42,
+	Reason:  "for fixing the build",
+	Limit:   5,
+	Wait:    30 * time.Second,
+	Text:    "Hello world",
+}
+
+// messageCatalog returns the parsed message catalogs, parsing them the first
+// time it is called. Messages that can't be parsed are left out, so that
+// English is sent instead, and reported once.
+func messageCatalog() map[string]map[string]*template.Template {
+	catalogOnce.Do(func() {
+		var err error
+		catalog, err = loadCatalog()
+		if err != nil {
+			fmt.Println("ERROR: unable to load messages:", err)
+		}
+	})
+	return catalog
+}
+
+// loadCatalog parses the messages shipped with BuddyBot. Every message that
+// can be parsed is returned, along with an error listing those that can't.
+func loadCatalog() (map[string]map[string]*template.Template, error) {
+	c := make(map[string]map[string]*template.Template)
+	var problems []string
+	for locale, texts := range defaultMessages {
+		c[locale] = make(map[string]*template.Template)
+		for name, text := range texts {
+			t, err := parseMessage(name, text)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", locale, err))
+				continue
+			}
+			c[locale][name] = t
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return c, errors.Errorf("invalid messages: %s", strings.Join(problems, ", "))
+	}
+	return c, nil
+}
+
+// MessageNames returns the name of every message a workspace can change.
+func MessageNames() []string {
+	names := make([]string, 0, len(defaultMessages[DefaultLocale]))
+	for n := range defaultMessages[DefaultLocale] {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Locales returns the locales BuddyBot ships messages for.
+func Locales() []string {
+	locales := make([]string, 0, len(defaultMessages))
+	for l := range defaultMessages {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// ValidateMessage checks that text is a valid template for the named message.
+func ValidateMessage(name, text string) error {
+	if _, ok := defaultMessages[DefaultLocale][name]; !ok {
+		return errors.Errorf("unknown message '%s'", name)
+	}
+	_, err := parseMessage(name, text)
+	return err
+}
+
+// parseMessage parses the template for a message and checks that it can be
+// executed, so that mistakes such as unknown fields are found before the
+// message is needed.
+func parseMessage(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse '%s'", name)
+	}
+
+	err = t.Execute(ioutil.Discard, sampleMessageData)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to execute '%s'", name)
+	}
+	return t, nil
+}
+
+// specialMentionRE matches mentions that notify many people at once, such as
+// <!channel>, <!here> and <!subteam^S0123|@team>.
+var specialMentionRE = regexp.MustCompile(`<!(channel|here|everyone|subteam\^\w+)(?:\|([^>]*))?>`)
+
+// QuietMentions replaces the mentions in text that notify many people at once
+// with plain text, so that text people give BuddyBot, such as the reason for a
+// vote, can't be used to ping a channel when BuddyBot posts it.
+func QuietMentions(text string) string {
+	return specialMentionRE.ReplaceAllStringFunc(text, func(m string) string {
+		sub := specialMentionRE.FindStringSubmatch(m)
+		name := sub[1]
+		if sub[2] != "" {
+			name = sub[2]
+		} else if strings.HasPrefix(name, "subteam") {
+			name = "group"
+		}
+		return "@" + strings.TrimPrefix(name, "@")
+	})
+}
+
+// localeRE matches a normalised locale, such as "fr" or "pt-br".
+var localeRE = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]+)*$`)
+
+// NormaliseLocale returns a locale such as "en-US" or "fr_FR" in the form
+// used to name message catalogs, "en-us" or "fr-fr".
+func NormaliseLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// candidates returns the locales to try for a message, most specific first.
+func candidates(locale string) []string {
+	locale = NormaliseLocale(locale)
+	if locale == "" {
+		return nil
+	}
+
+	c := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		c = append(c, locale[:i])
+	}
+	return c
+}
+
+// Message renders the named message for the first of the locales given that
+// there is a version of it for, trying the workspace's locale and then
+// DefaultLocale last. Messages the workspace has changed for one of the
+// locales take precedence, followed by those it has changed for every locale,
+// and then those shipped with BuddyBot.
+func (s Settings) Message(name string, data MessageData, locales ...string) string {
+	var try []string
+	for _, l := range locales {
+		try = append(try, candidates(l)...)
+	}
+	try = append(try, candidates(s.Locale)...)
+	try = append(try, DefaultLocale)
+
+	var texts []string
+	for _, l := range try {
+		if text, ok := s.Messages[name+"."+l]; ok {
+			texts = append(texts, text)
+		}
+	}
+	if text, ok := s.Messages[name]; ok {
+		texts = append(texts, text)
+	}
+
+	for _, text := range texts {
+		msg, err := renderMessage(name, text, data)
+		if err == nil {
+			return msg
+		}
+		fmt.Println("WARN: unable to render message, using the default:", err)
+	}
+
+	c := messageCatalog()
+	for _, l := range try {
+		if t, ok := c[l][name]; ok {
+			buf := new(bytes.Buffer)
+			err := t.Execute(buf, data)
+			if err == nil {
+				return buf.String()
+			}
+			fmt.Println("WARN: unable to render message:", err)
+		}
+	}
+
+	return ""
+}
+
+// renderMessage renders a message a workspace has changed.
+func renderMessage(name, text string, data MessageData) (string, error) {
+	t, err := parseMessage(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to execute '%s'", name)
+	}
+	return buf.String(), nil
+}
+
+// userInfoResponse is the part of the users.info response we use.
+type userInfoResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+	User  struct {
+		Locale string `json:"locale"`
+	} `json:"user"`
+}
+
+// Limits on looking up a user's locale, which happens while replying to
+// votes. Locales are remembered for localeTTL, and after failing to find one
+// we wait localeRetry before asking Slack again.
+const (
+	localeTimeout = 3 * time.Second
+	localeTTL     = time.Hour
+	localeRetry   = 5 * time.Minute
+)
+
+// localeClient is used to look up users' locales.
+var localeClient = &http.Client{Timeout: localeTimeout}
+
+// cachedLocale is a user's locale and when we should next ask Slack for it.
+type cachedLocale struct {
+	locale  string
+	expires time.Time
+}
+
+// userLocales remembers the locales users have chosen, by user ID.
+var (
+	userLocalesMu sync.Mutex
+	userLocales   = make(map[string]cachedLocale)
+)
+
+// UserLocale returns the locale a user has chosen in Slack, such as "en-US".
+// Locales are remembered for an hour. If Slack can't tell us, the error is
+// returned and "" is returned for the user until it's time to ask again.
+func UserLocale(token, user string) (string, error) {
+	now := time.Now()
+
+	userLocalesMu.Lock()
+	c, ok := userLocales[user]
+	userLocalesMu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.locale, nil
+	}
+
+	locale, err := fetchUserLocale(token, user)
+	c = cachedLocale{locale: locale, expires: now.Add(localeTTL)}
+	if err != nil {
+		c.expires = now.Add(localeRetry)
+	}
+
+	userLocalesMu.Lock()
+	defer userLocalesMu.Unlock()
+	for u, cl := range userLocales {
+		if !now.Before(cl.expires) {
+			delete(userLocales, u)
+		}
+	}
+	userLocales[user] = c
+
+	return locale, err
+}
+
+// fetchUserLocale asks Slack for the locale a user has chosen.
+func fetchUserLocale(token, user string) (string, error) {
+	v := url.Values{}
+	v.Set("token", token)
+	v.Set("user", user)
+	v.Set("include_locale", "true")
+
+	resp, err := localeClient.PostForm(slackAPI+"users.info", v)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to call users.info")
+	}
+	defer resp.Body.Close()
+
+	ur := userInfoResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&ur)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decode users.info response")
+	}
+
+	if !ur.Ok {
+		return "", errors.Errorf("users.info failed: %s", ur.Error)
+	}
+	return ur.User.Locale, nil
+}
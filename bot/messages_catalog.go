@@ -0,0 +1,55 @@
+package bot
+
+// defaultMessages holds the messages shipped with BuddyBot, by locale and then
+// by name. Each is a text/template, see MessageData for the values available
+// to them. Messages missing from a locale are sent in English.
+var defaultMessages = map[string]map[string]string{
+	"en": {
+		"plus":             "Congrats {{.User}}! Score now at {{.Score}} :smile:",
+		"plusFailed":       "Congrats {{.User}}! I was unable to update your score, so you'll have to accept this smile instead :smile:",
+		"minus":            "Ouch {{.User}}! Score now down to {{.Score}} :disappointed:",
+		"minusFailed":      "Ouch {{.User}}! Luckily for you I was unable to update your score :sweat_smile:",
+		"thingPlus":        "{{.Subject}}++ :tada: Score now at {{.Score}}",
+		"thingPlusFailed":  "{{.Subject}}++ :tada: I was unable to update its score, but the thought counts.",
+		"thingMinus":       "{{.Subject}}-- :grimacing: Score now down to {{.Score}}",
+		"thingMinusFailed": "{{.Subject}}-- :grimacing: I was unable to update its score.",
+		"reason":           "> {{.Reason}}",
+		"selfPlus":         "No {{.User}}, try patting yourself on the back instead :stuck_out_tongue_closed_eyes:",
+		"selfMinus":        "Don't be so hard on yourself {{.User}} :hugging_face:",
+		"limitCooldown":    "Steady on! You can give more points in {{.Wait}}.",
+		"limitReceiver":    "You've given {{.Subject}} all the points you can for now. Why not thank someone else?",
+		"limitDaily":       "You've given all {{.Limit}} of your points. Your allowance resets in {{.Wait}}.",
+		"flagReporter": `This message has been flagged!
+We'll review it against our Code of Conduct and take appropriate action. If we need more information, one of the admins will be in touch privately for more information.`,
+		"flagAuthor": `This message that you posted has been flagged as potentially violating our Code of Conduct!
+> "{{.Text}}"
+
+The message may be removed or one of the admins may be in touch shortly to discuss this post. We know that not all CoC breaches are intentional, so please consider reviewing your post and notifying the thread of any changes.`,
+		"flagTitle":   "Flagged message",
+		"flagPretext": "The message below has been flagged for a potential CoC violation",
+	},
+	"fr": {
+		"plus":             "Bravo {{.User}} ! Score maintenant à {{.Score}} :smile:",
+		"plusFailed":       "Bravo {{.User}} ! Je n'ai pas pu mettre à jour ton score, alors contente-toi de ce sourire :smile:",
+		"minus":            "Aïe {{.User}} ! Score maintenant descendu à {{.Score}} :disappointed:",
+		"minusFailed":      "Aïe {{.User}} ! Heureusement pour toi, je n'ai pas pu mettre à jour ton score :sweat_smile:",
+		"thingPlus":        "{{.Subject}}++ :tada: Score maintenant à {{.Score}}",
+		"thingPlusFailed":  "{{.Subject}}++ :tada: Je n'ai pas pu mettre à jour son score, mais c'est l'intention qui compte.",
+		"thingMinus":       "{{.Subject}}-- :grimacing: Score maintenant descendu à {{.Score}}",
+		"thingMinusFailed": "{{.Subject}}-- :grimacing: Je n'ai pas pu mettre à jour son score.",
+		"reason":           "> {{.Reason}}",
+		"selfPlus":         "Non {{.User}}, essaie plutôt de te féliciter toi-même :stuck_out_tongue_closed_eyes:",
+		"selfMinus":        "Ne sois pas si dur avec toi-même {{.User}} :hugging_face:",
+		"limitCooldown":    "Doucement ! Tu pourras donner d'autres points dans {{.Wait}}.",
+		"limitReceiver":    "Tu as donné à {{.Subject}} tous les points possibles pour l'instant. Pourquoi ne pas remercier quelqu'un d'autre ?",
+		"limitDaily":       "Tu as donné tes {{.Limit}} points. Ton quota se renouvelle dans {{.Wait}}.",
+		"flagReporter": `Ce message a été signalé !
+Nous allons le comparer à notre Code de conduite et prendre les mesures appropriées. Si nous avons besoin de plus d'informations, un des admins te contactera en privé.`,
+		"flagAuthor": `Un message que tu as publié a été signalé comme enfreignant potentiellement notre Code de conduite !
+> "{{.Text}}"
+
+Le message pourra être supprimé, ou un des admins te contactera bientôt pour en discuter. Nous savons que toutes les infractions ne sont pas intentionnelles, alors pense à relire ton message et à signaler tes modifications dans le fil.`,
+		"flagTitle":   "Message signalé",
+		"flagPretext": "Le message ci-dessous a été signalé pour une possible infraction au Code de conduite",
+	},
+}
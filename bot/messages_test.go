@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	c, err := loadCatalog()
+	if err != nil {
+		t.Fatal("should parse the shipped messages:", err)
+	}
+
+	for locale, messages := range c {
+		for name := range messages {
+			if _, ok := c[DefaultLocale][name]; !ok {
+				t.Errorf("%s should have a default for '%s'", locale, name)
+			}
+		}
+		for name := range c[DefaultLocale] {
+			if _, ok := messages[name]; !ok {
+				t.Errorf("%s should have a translation of '%s'", locale, name)
+			}
+		}
+	}
+}
+
+func TestLoadCatalogInvalid(t *testing.T) {
+	messages := defaultMessages
+	defer func() { defaultMessages = messages }()
+
+	defaultMessages = map[string]map[string]string{
+		"en": {"plus": "Congrats {{.User}}"},
+		"fr": {"plus": "Bravo {{.Points}}"},
+	}
+
+	c, err := loadCatalog()
+	if err == nil || !strings.Contains(err.Error(), "fr") {
+		t.Error("should report invalid messages, got:", err)
+	}
+	if _, ok := c[DefaultLocale]["plus"]; !ok {
+		t.Error("should keep the messages that parse")
+	}
+	if _, ok := c["fr"]["plus"]; ok {
+		t.Error("should leave out the messages that don't parse")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	data := MessageData{User: "<@U1>", Score: 3}
+
+	testCases := []struct {
+		name     string
+		settings Settings
+		locales  []string
+		want     string
+	}{
+		{name: "default", want: "Congrats <@U1>! Score now at 3 :smile:"},
+		{name: "workspace locale", settings: Settings{Locale: "fr"}, want: "Bravo <@U1> ! Score maintenant à 3 :smile:"},
+		{name: "user locale", locales: []string{"fr-FR"}, want: "Bravo <@U1> ! Score maintenant à 3 :smile:"},
+		{name: "unknown locale", locales: []string{"xx-YY"}, settings: Settings{Locale: "zz"}, want: "Congrats <@U1>! Score now at 3 :smile:"},
+		{name: "override", settings: Settings{Messages: map[string]string{"plus": "Nice one {{.User}}"}}, locales: []string{"fr"}, want: "Nice one <@U1>"},
+		{name: "override for locale", settings: Settings{Messages: map[string]string{"plus": "Nice one {{.User}}", "plus.fr": "Merci {{.User}}"}}, locales: []string{"fr-BE"}, want: "Merci <@U1>"},
+		{name: "invalid override", settings: Settings{Messages: map[string]string{"plus": "{{.Points}}"}}, want: "Congrats <@U1>! Score now at 3 :smile:"},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.settings.Message("plus", data, tc.locales...); got != tc.want {
+			t.Errorf("%s: should return %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestQuietMentions(t *testing.T) {
+	testCases := map[string]string{
+		"for fixing the build":                                              "for fixing the build",
+		"for telling <!channel> about it":                                   "for telling @channel about it",
+		"<!here> <!everyone|everyone>":                                      "@here @everyone",
+		"thanks <!subteam^S0123|@platform> team":                            "thanks @platform team",
+		"thanks <!subteam^S0123>":                                           "thanks @group",
+		"for <@U0123> and <#C0123|general> <!date^1531420618^{date}|today>": "for <@U0123> and <#C0123|general> <!date^1531420618^{date}|today>",
+	}
+	for text, want := range testCases {
+		if got := QuietMentions(text); got != want {
+			t.Errorf("QuietMentions(%q) should be %q, got %q", text, want, got)
+		}
+	}
+}
+
+func TestUserLocale(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.FormValue("include_locale") != "true" || r.FormValue("token") != "xoxb-1" {
+			t.Error("should ask for the user's locale, got:", r.Form)
+		}
+		switch r.FormValue("user") {
+		case "U1":
+			fmt.Fprint(w, `{"ok": true, "user": {"id": "U1", "locale": "fr-FR"}}`)
+		case "U3":
+			time.Sleep(200 * time.Millisecond)
+			fmt.Fprint(w, `{"ok": true, "user": {"id": "U3", "locale": "de-DE"}}`)
+		default:
+			fmt.Fprint(w, `{"ok": false, "error": "user_not_found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	api, client := slackAPI, localeClient
+	slackAPI = srv.URL + "/"
+	localeClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { slackAPI, localeClient = api, client }()
+
+	userLocales = make(map[string]cachedLocale)
+	defer func() { userLocales = make(map[string]cachedLocale) }()
+
+	for i := 0; i < 2; i++ {
+		locale, err := UserLocale("xoxb-1", "U1")
+		if err != nil || locale != "fr-FR" {
+			t.Errorf("should return the user's locale, got %s (%v)", locale, err)
+		}
+	}
+	if calls != 1 {
+		t.Error("should remember the user's locale, got calls:", calls)
+	}
+
+	if _, err := UserLocale("xoxb-1", "U2"); err == nil {
+		t.Error("should return an error for unknown users")
+	}
+	if locale, err := UserLocale("xoxb-1", "U2"); err != nil || locale != "" {
+		t.Errorf("should not ask again straight after failing, got %s (%v)", locale, err)
+	}
+
+	if _, err := UserLocale("xoxb-1", "U3"); err == nil {
+		t.Error("should give up on slow responses")
+	}
+}
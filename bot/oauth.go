@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// slackAPI is the base URL for Slack Web API methods. It is a variable so
+// tests can point it at a local server.
+var slackAPI = "https://slack.com/api/"
+
+// refreshMargin is how long before expiry a token is refreshed.
+const refreshMargin = 5 * time.Minute
+
+// OAuthResponse represents the response we receive from the Slack
+// oauth.v2.access method, both when a workspace adds BuddyBot and when a
+// token is refreshed.
+type OAuthResponse struct {
+	Ok           bool   `json:"ok"`
+	Error        string `json:"error"`
+	AppID        string `json:"app_id"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	BotUserID    string `json:"bot_user_id"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Team         struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+	Enterprise *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"enterprise"`
+	IsEnterpriseInstall bool `json:"is_enterprise_install"`
+	AuthedUser          struct {
+		ID           string `json:"id"`
+		Scope        string `json:"scope"`
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	} `json:"authed_user"`
+}
+
+// ExchangeCode swaps the temporary code Slack gives us when a workspace adds
+// BuddyBot for access tokens. The redirectURI must match the one used to start
+// the install.
+func (b *SlackBot) ExchangeCode(code, redirectURI string) (OAuthResponse, error) {
+	v := url.Values{}
+	v.Set("code", code)
+	v.Set("redirect_uri", redirectURI)
+	return b.oauthAccess(v)
+}
+
+// NewAuthRecord converts an OAuth response into the install record we store.
+// Token expiry times are calculated relative to now.
+func NewAuthRecord(ar OAuthResponse, now time.Time) AuthRecord {
+	rec := AuthRecord{
+		UID:             ar.Team.ID,
+		AppID:           ar.AppID,
+		AccessToken:     ar.AuthedUser.AccessToken,
+		RefreshToken:    ar.AuthedUser.RefreshToken,
+		TokenExpiry:     expiry(now, ar.AuthedUser.ExpiresIn),
+		Scope:           ar.AuthedUser.Scope,
+		UserID:          ar.AuthedUser.ID,
+		TeamName:        ar.Team.Name,
+		TeamID:          ar.Team.ID,
+		BotUserID:       ar.BotUserID,
+		BotAccessToken:  ar.AccessToken,
+		BotRefreshToken: ar.RefreshToken,
+		BotTokenExpiry:  expiry(now, ar.ExpiresIn),
+		BotScope:        ar.Scope,
+	}
+
+	if ar.Enterprise != nil {
+		rec.EnterpriseID = ar.Enterprise.ID
+		rec.EnterpriseName = ar.Enterprise.Name
+	}
+
+	// org-wide installs cover every workspace in the org so are keyed on the
+	// enterprise rather than a team
+	if ar.IsEnterpriseInstall && ar.Enterprise != nil {
+		rec.UID = ar.Enterprise.ID
+		rec.TeamName = ar.Enterprise.Name
+		rec.OrgInstall = true
+	}
+
+	return rec
+}
+
+// refreshTokens renews any token in rec that has expired or is about to. It
+// reports whether rec was changed.
+func (b *SlackBot) refreshTokens(rec *AuthRecord) (bool, error) {
+	now := b.now()
+	changed := false
+
+	if needsRefresh(rec.BotTokenExpiry, rec.BotRefreshToken, now) {
+		ar, err := b.refresh(rec.BotRefreshToken)
+		if err != nil {
+			return changed, errors.Wrap(err, "unable to refresh bot token")
+		}
+		rec.BotAccessToken = ar.AccessToken
+		rec.BotRefreshToken = ar.RefreshToken
+		rec.BotTokenExpiry = expiry(now, ar.ExpiresIn)
+		changed = true
+	}
+
+	if needsRefresh(rec.TokenExpiry, rec.RefreshToken, now) {
+		ar, err := b.refresh(rec.RefreshToken)
+		if err != nil {
+			return changed, errors.Wrap(err, "unable to refresh user token")
+		}
+		rec.AccessToken = ar.AccessToken
+		rec.RefreshToken = ar.RefreshToken
+		rec.TokenExpiry = expiry(now, ar.ExpiresIn)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// refresh exchanges a refresh token for a new access token.
+func (b *SlackBot) refresh(token string) (OAuthResponse, error) {
+	v := url.Values{}
+	v.Set("grant_type", "refresh_token")
+	v.Set("refresh_token", token)
+	return b.oauthAccess(v)
+}
+
+// oauthAccess calls the oauth.v2.access method with the given form values,
+// authenticating as the BuddyBot app.
+func (b *SlackBot) oauthAccess(v url.Values) (OAuthResponse, error) {
+	ar := OAuthResponse{}
+
+	r, err := http.NewRequest(http.MethodPost, slackAPI+"oauth.v2.access", strings.NewReader(v.Encode()))
+	if err != nil {
+		return ar, err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(b.ClientID, b.ClientSecret)
+
+	resp, err := b.httpClient().Do(r)
+	if err != nil {
+		return ar, errors.Wrap(err, "unable to call oauth.v2.access")
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&ar)
+	if err != nil {
+		return ar, errors.Wrap(err, "unable to decode oauth.v2.access response")
+	}
+
+	if !ar.Ok {
+		return ar, errors.Errorf("oauth.v2.access failed: %s", ar.Error)
+	}
+
+	return ar, nil
+}
+
+// needsRefresh reports whether a token expiring at expiry (in Unix seconds)
+// should be refreshed. Tokens without an expiry or a refresh token never are.
+func needsRefresh(expiry int64, refreshToken string, now time.Time) bool {
+	if expiry == 0 || refreshToken == "" {
+		return false
+	}
+	return now.Add(refreshMargin).Unix() >= expiry
+}
+
+// expiry returns the Unix time at which a token issued now expires, or zero
+// if the token doesn't expire.
+func expiry(now time.Time, expiresIn int64) int64 {
+	if expiresIn == 0 {
+		return 0
+	}
+	return now.Unix() + expiresIn
+}
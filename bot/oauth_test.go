@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetrieveTokensRefresh(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+
+	var refreshed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "id" || secret != "secret" {
+			t.Error("should authenticate as the app")
+		}
+		if r.FormValue("grant_type") != "refresh_token" {
+			t.Error("should request a refresh, got:", r.FormValue("grant_type"))
+		}
+		refreshed = append(refreshed, r.FormValue("refresh_token"))
+		fmt.Fprint(w, `{"ok": true, "access_token": "xoxb-new", "refresh_token": "xoxe-new", "expires_in": 43200}`)
+	}))
+	defer srv.Close()
+
+	api := slackAPI
+	slackAPI = srv.URL + "/"
+	defer func() { slackAPI = api }()
+
+	s := NewMemoryStore()
+	s.PutAuth(AuthRecord{
+		UID:             "T123",
+		UserID:          "U1",
+		AccessToken:     "xoxp-static",
+		BotAccessToken:  "xoxb-old",
+		BotRefreshToken: "xoxe-old",
+		BotTokenExpiry:  now.Add(time.Minute).Unix(),
+	})
+
+	b := &SlackBot{ClientID: "id", ClientSecret: "secret", Store: s, Clock: func() time.Time { return now }}
+
+	bot, usr, _, err := b.RetrieveTokens("T123")
+	if err != nil {
+		t.Fatal("should retrieve tokens:", err)
+	}
+	if bot != "xoxb-new" || usr != "xoxp-static" {
+		t.Errorf("should only refresh the expiring token, got %s %s", bot, usr)
+	}
+	if len(refreshed) != 1 || refreshed[0] != "xoxe-old" {
+		t.Errorf("should refresh once using the stored refresh token, got %v", refreshed)
+	}
+
+	rec, _ := s.GetAuth("T123")
+	if rec.BotRefreshToken != "xoxe-new" || rec.BotTokenExpiry != now.Unix()+43200 {
+		t.Errorf("should store the refreshed token and its expiry, got %+v", rec)
+	}
+
+	b.RetrieveTokens("T123")
+	if len(refreshed) != 1 {
+		t.Error("should not refresh a token that is still valid")
+	}
+}
+
+func TestExchangeCodeTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	api := slackAPI
+	slackAPI = srv.URL + "/"
+	defer func() { slackAPI = api }()
+
+	b := &SlackBot{HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}}
+
+	start := time.Now()
+	if _, err := b.ExchangeCode("code", "https://example.com/auth"); err == nil {
+		t.Error("should give up when Slack doesn't answer")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("should use the client's timeout, took:", time.Since(start))
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/nlopes/slack/slackevents"
+)
+
+// Events API types sent when a reaction is added to or removed from an item.
+const (
+	ReactionAdded   = "reaction_added"
+	ReactionRemoved = "reaction_removed"
+)
+
+// ReactionEvent is sent when a user adds or removes a reaction. ItemUser is
+// the user who created the item reacted to.
+type ReactionEvent struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	Reaction string `json:"reaction"`
+	ItemUser string `json:"item_user"`
+	Item     struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	} `json:"item"`
+	EventTimestamp string `json:"event_ts"`
+}
+
+func init() {
+	// slackevents doesn't yet know about reactions
+	slackevents.EventsAPIInnerEventMapping[ReactionAdded] = ReactionEvent{}
+	slackevents.EventsAPIInnerEventMapping[ReactionRemoved] = ReactionEvent{}
+}
+
+// emojiName returns the name of an emoji without surrounding colons or a skin
+// tone, so that :+1::skin-tone-2: is treated as :+1:.
+func emojiName(name string) string {
+	name = strings.Trim(strings.TrimSpace(name), ":")
+	return strings.SplitN(name, "::", 2)[0]
+}
+
+// ReactionReason returns the reason recorded on awards made by reacting with
+// an emoji.
+func ReactionReason(name string) string {
+	return ":" + emojiName(name) + ":"
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReplyTTL is how long BuddyBot remembers the reply it posted to a message.
+// Corrections to messages edited after this still apply, but the reply is
+// left as it was.
+const ReplyTTL = 7 * 24 * time.Hour
+
+// ReplyStyle is how BuddyBot responds to the votes in a message.
+type ReplyStyle string
+
+// Reply styles a workspace can choose from.
+const (
+	ReplyThread    ReplyStyle = "thread"    // a reply in the message's thread
+	ReplyChannel   ReplyStyle = "channel"   // a reply in the channel
+	ReplyEphemeral ReplyStyle = "ephemeral" // a reply only the voter can see
+	ReplyReaction  ReplyStyle = "reaction"  // a reaction to the message
+)
+
+// DefaultReplyEmoji is the reaction added to a message when votes are
+// acknowledged with a reaction.
+const DefaultReplyEmoji = "tada"
+
+// ParseReplyStyle returns the ReplyStyle with the given name.
+func ParseReplyStyle(s string) (ReplyStyle, error) {
+	switch r := ReplyStyle(strings.ToLower(s)); r {
+	case ReplyThread, ReplyChannel, ReplyEphemeral, ReplyReaction:
+		return r, nil
+	default:
+		return ReplyThread, errors.Errorf("unknown reply style '%s'", s)
+	}
+}
+
+// reply is the reply a Store keeps for a message, with the time it may be
+// forgotten.
+type reply struct {
+	TS      string    `json:"ts"`
+	Expires time.Time `json:"expires"`
+}
+
+// RecordReply remembers the reply posted about the votes in a message, so that
+// it can be corrected if the message is edited.
+func (b *SlackBot) RecordReply(team, channel, messageTS, replyTS string) error {
+	err := b.Store.PutReply(replyKey(team, channel, messageTS), replyTS, b.now().Add(ReplyTTL))
+	if err != nil {
+		return errors.Wrap(err, "unable to record reply")
+	}
+	return nil
+}
+
+// Reply returns the timestamp of the reply posted about the votes in a
+// message. It returns ErrNotFound if there is no reply.
+func (b *SlackBot) Reply(team, channel, messageTS string) (string, error) {
+	ts, err := b.Store.GetReply(replyKey(team, channel, messageTS), b.now())
+	if err == ErrNotFound {
+		return "", err
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get reply")
+	}
+	return ts, nil
+}
+
+// ForgetReply removes the reply posted about the votes in a message, once it
+// has been deleted.
+func (b *SlackBot) ForgetReply(team, channel, messageTS string) error {
+	err := b.Store.DeleteReply(replyKey(team, channel, messageTS))
+	if err != nil {
+		return errors.Wrap(err, "unable to forget reply")
+	}
+	return nil
+}
+
+// replyKey returns the key under which the reply to a message is stored.
+func replyKey(team, channel, messageTS string) string {
+	return fmt.Sprintf("reply:%s:%s:%s", team, channel, messageTS)
+}
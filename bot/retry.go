@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pkg/errors"
+)
+
+// EventTTL is how long the record that an event has been processed is kept.
+// Slack stops retrying an event well within this time.
+const EventTTL = 24 * time.Hour
+
+// ErrDuplicateEvent is returned by a Store when an event has already been
+// claimed.
+var ErrDuplicateEvent = errors.New("event already processed")
+
+// SlackRetry returns the number of times Slack has retried sending a request,
+// along with the reason it gave, e.g. http_timeout. The first attempt has a
+// retry number of zero.
+func SlackRetry(req events.APIGatewayProxyRequest) (int, string) {
+	n, err := strconv.Atoi(req.Headers["X-Slack-Retry-Num"])
+	if err != nil {
+		return 0, ""
+	}
+	return n, req.Headers["X-Slack-Retry-Reason"]
+}
+
+// ClaimEvent records that an event is being processed for a receiver, so that
+// retries of the same event don't award points twice. It returns false if the
+// event has already been claimed for the receiver. Events without an ID
+// can't be told apart, so are always claimed.
+func (b *SlackBot) ClaimEvent(eventID, receiver string) (bool, error) {
+	if eventID == "" {
+		return true, nil
+	}
+
+	now := b.now()
+	err := b.Store.ClaimEvent(eventKey(eventID, receiver), now, now.Add(EventTTL))
+	if err == ErrDuplicateEvent {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim event")
+	}
+	return true, nil
+}
+
+// ReleaseEvent removes the claim on an event for a receiver, so that a retry
+// can process it after a failure.
+func (b *SlackBot) ReleaseEvent(eventID, receiver string) error {
+	if eventID == "" {
+		return nil
+	}
+
+	err := b.Store.ReleaseEvent(eventKey(eventID, receiver))
+	if err != nil {
+		return errors.Wrap(err, "unable to release event")
+	}
+	return nil
+}
+
+// eventKey returns the key under which the claim on an event for a receiver
+// is stored.
+func eventKey(eventID, receiver string) string {
+	return fmt.Sprintf("%s:%s", eventID, receiver)
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestSlackRetry(t *testing.T) {
+	req := events.APIGatewayProxyRequest{Headers: map[string]string{"X-Slack-Retry-Num": "2", "X-Slack-Retry-Reason": "http_timeout"}}
+	if n, reason := SlackRetry(req); n != 2 || reason != "http_timeout" {
+		t.Errorf("should return the retry number and reason, got %d %s", n, reason)
+	}
+
+	if n, _ := SlackRetry(events.APIGatewayProxyRequest{}); n != 0 {
+		t.Error("should treat requests without retry headers as the first attempt, got:", n)
+	}
+}
+
+func TestClaimEvent(t *testing.T) {
+	now := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	b := &SlackBot{Store: NewMemoryStore(), Clock: func() time.Time { return now }}
+
+	if claimed, err := b.ClaimEvent("Ev1", "U1"); !claimed || err != nil {
+		t.Fatalf("should claim a new event, got %t (%v)", claimed, err)
+	}
+	if claimed, _ := b.ClaimEvent("Ev1", "U1"); claimed {
+		t.Error("should not claim a retried event")
+	}
+
+	b.ReleaseEvent("Ev1", "U1")
+	if claimed, _ := b.ClaimEvent("Ev1", "U1"); !claimed {
+		t.Error("should claim a released event")
+	}
+
+	now = now.Add(EventTTL)
+	if claimed, _ := b.ClaimEvent("Ev1", "U1"); !claimed {
+		t.Error("should forget events once the claim expires")
+	}
+
+	if claimed, _ := b.ClaimEvent("", "U1"); !claimed {
+		t.Error("should always claim events without an ID")
+	}
+}
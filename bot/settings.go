@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Settings holds the options a workspace can change. The zero value is the
+// default behaviour, so workspaces that have never changed anything don't
+// need a stored record.
+type Settings struct {
+	// DisableMinusMinus stops <@user>-- from taking points away.
+	DisableMinusMinus bool `json:"disableMinusMinus"`
+
+	// DailyLimit is the number of awards each user may give a day. Days
+	// start with a user's first award, see AllowanceWindow.
+	DailyLimit int `json:"dailyLimit"`
+
+	// DailyLimitPerReceiver is the number of awards each user may give to
+	// the same person a day.
+	DailyLimitPerReceiver int `json:"dailyLimitPerReceiver"`
+
+	// Cooldown is how long a user must wait between giving awards.
+	Cooldown time.Duration `json:"cooldown"`
+
+	// AdminChannel is the ID of the channel where messages for admins are
+	// posted. If empty, the private channel named "admins" is used.
+	AdminChannel string `json:"adminChannel"`
+
+	// ScanChannels are the IDs of the channels where every message is
+	// checked for votes, not just those that mention BuddyBot.
+	ScanChannels []string `json:"scanChannels,omitempty"`
+
+	// Reactions maps the name of an emoji onto the points given by reacting
+	// to a message with it.
+	Reactions map[string]int `json:"reactions,omitempty"`
+
+	// ReplyStyle is how BuddyBot responds to votes. If empty, it replies in
+	// the thread of the message with the votes.
+	ReplyStyle ReplyStyle `json:"replyStyle,omitempty"`
+
+	// ReplyEmoji is the reaction used when ReplyStyle is ReplyReaction. If
+	// empty, DefaultReplyEmoji is used.
+	ReplyEmoji string `json:"replyEmoji,omitempty"`
+
+	// Locale is the locale messages are sent in, unless they are for a user
+	// who has chosen another. If empty, DefaultLocale is used.
+	Locale string `json:"locale,omitempty"`
+
+	// Messages maps the name of a message onto the template used instead of
+	// the one shipped with BuddyBot. Names suffixed with a locale, as in
+	// "plus.fr", only apply to that locale.
+	Messages map[string]string `json:"messages,omitempty"`
+}
+
+// Replies returns how BuddyBot responds to votes.
+func (s Settings) Replies() ReplyStyle {
+	if s.ReplyStyle == "" {
+		return ReplyThread
+	}
+	return s.ReplyStyle
+}
+
+// Emoji returns the reaction used to acknowledge votes.
+func (s Settings) Emoji() string {
+	if s.ReplyEmoji == "" {
+		return DefaultReplyEmoji
+	}
+	return s.ReplyEmoji
+}
+
+// ReactionPoints returns the points given by reacting with an emoji, or zero
+// if the emoji doesn't give points.
+func (s Settings) ReactionPoints(emoji string) int {
+	return s.Reactions[emojiName(emoji)]
+}
+
+// Scans reports whether every message in a channel is checked for votes.
+func (s Settings) Scans(channel string) bool {
+	for _, c := range s.ScanChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits returns the limits on giving awards.
+func (s Settings) Limits() Limits {
+	return Limits{
+		PerDay:      s.DailyLimit,
+		PerReceiver: s.DailyLimitPerReceiver,
+		Cooldown:    s.Cooldown,
+	}
+}
+
+// settingSetters maps the name of each setting onto a function that parses
+// and applies a new value.
+var settingSetters = map[string]func(s *Settings, v string) error{
+	"disableMinusMinus": func(s *Settings, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		s.DisableMinusMinus = b
+		return nil
+	},
+	"dailyLimit": func(s *Settings, v string) error {
+		return setLimit(&s.DailyLimit, v)
+	},
+	"dailyLimitPerReceiver": func(s *Settings, v string) error {
+		return setLimit(&s.DailyLimitPerReceiver, v)
+	},
+	"cooldown": func(s *Settings, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return errors.New("must be a duration such as 30s or 5m, or 0 for none")
+		}
+		s.Cooldown = d
+		return nil
+	},
+	"adminChannel": func(s *Settings, v string) error {
+		if v != "" && !isChannelID(v) {
+			return errors.New("must be a channel ID such as C0123ABCD")
+		}
+		s.AdminChannel = v
+		return nil
+	},
+	"scanChannels": func(s *Settings, v string) error {
+		var channels []string
+		for _, c := range strings.Split(v, ",") {
+			c = strings.TrimSpace(c)
+			if c == "" || (Settings{ScanChannels: channels}).Scans(c) {
+				continue
+			}
+			if !isChannelID(c) {
+				return errors.New("must be a comma separated list of channel IDs such as C0123ABCD,G0123ABCD")
+			}
+			channels = append(channels, c)
+		}
+		s.ScanChannels = channels
+		return nil
+	},
+	"reactions": func(s *Settings, v string) error {
+		reactions := make(map[string]int)
+		for _, r := range strings.Split(v, ",") {
+			if strings.TrimSpace(r) == "" {
+				continue
+			}
+			parts := strings.SplitN(r, "=", 2)
+			if len(parts) != 2 || emojiName(parts[0]) == "" {
+				return errors.New("must be a comma separated list of emoji and points such as taco=1,+1=1")
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil || n == 0 {
+				return errors.Errorf("points for '%s' must be a whole number other than 0", emojiName(parts[0]))
+			}
+			reactions[emojiName(parts[0])] = n
+		}
+		s.Reactions = reactions
+		if len(reactions) == 0 {
+			s.Reactions = nil
+		}
+		return nil
+	},
+	"replyStyle": func(s *Settings, v string) error {
+		if v == "" {
+			s.ReplyStyle = ""
+			return nil
+		}
+		r, err := ParseReplyStyle(v)
+		if err != nil {
+			return errors.New("must be thread, channel, ephemeral or reaction")
+		}
+		s.ReplyStyle = r
+		return nil
+	},
+	"replyEmoji": func(s *Settings, v string) error {
+		name := emojiName(v)
+		if strings.ContainsAny(name, " :") {
+			return errors.New("must be the name of an emoji such as tada")
+		}
+		s.ReplyEmoji = name
+		return nil
+	},
+	"locale": func(s *Settings, v string) error {
+		locale := NormaliseLocale(v)
+		if locale != "" && !localeRE.MatchString(locale) {
+			return errors.New("must be a locale such as en or fr-FR")
+		}
+		s.Locale = locale
+		return nil
+	},
+}
+
+// messagePrefix starts the name of every setting that changes a message.
+const messagePrefix = "message."
+
+// setMessage changes the template for a message, where name is the message's
+// name optionally followed by a locale, as in "plus" or "plus.fr". An empty
+// template reverts to the message shipped with BuddyBot.
+func (s *Settings) setMessage(name, v string) error {
+	parts := strings.SplitN(name, ".", 2)
+	key := parts[0]
+	if len(parts) == 2 {
+		locale := NormaliseLocale(parts[1])
+		if !localeRE.MatchString(locale) {
+			return errors.Errorf("'%s' is not a locale such as en or fr-FR", parts[1])
+		}
+		key += "." + locale
+	}
+
+	if v == "" {
+		delete(s.Messages, key)
+		if len(s.Messages) == 0 {
+			s.Messages = nil
+		}
+		return nil
+	}
+
+	err := ValidateMessage(parts[0], v)
+	if err != nil {
+		return err
+	}
+
+	if s.Messages == nil {
+		s.Messages = make(map[string]string)
+	}
+	s.Messages[key] = v
+	return nil
+}
+
+// isChannelID reports whether v looks like the ID of a public or private
+// channel.
+func isChannelID(v string) bool {
+	return strings.HasPrefix(v, "C") || strings.HasPrefix(v, "G")
+}
+
+// setLimit parses a limit, where zero means there is no limit.
+func setLimit(limit *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return errors.New("must be a whole number, or 0 for no limit")
+	}
+	*limit = n
+	return nil
+}
+
+// Set changes the named setting to the value given. It returns an error if
+// the setting doesn't exist or the value isn't valid for it.
+func (s *Settings) Set(name, value string) error {
+	if strings.HasPrefix(name, messagePrefix) {
+		err := s.setMessage(strings.TrimPrefix(name, messagePrefix), value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value for '%s'", name)
+		}
+		return nil
+	}
+
+	set, ok := settingSetters[name]
+	if !ok {
+		return errors.Errorf("unknown setting '%s'", name)
+	}
+
+	err := set(s, value)
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for '%s'", name)
+	}
+	return nil
+}
+
+// SettingNames returns the name of every setting a workspace can change.
+// Messages may also be changed for a single locale by adding it to the name,
+// as in "message.plus.fr".
+func SettingNames() []string {
+	names := make([]string, 0, len(settingSetters))
+	for n := range settingSetters {
+		names = append(names, n)
+	}
+	for _, n := range MessageNames() {
+		names = append(names, messagePrefix+n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSettingsSet(t *testing.T) {
+	s := Settings{}
+
+	for name, value := range map[string]string{"disableMinusMinus": "true", "dailyLimit": "10", "dailyLimitPerReceiver": "3", "cooldown": "30s", "adminChannel": "G123", "scanChannels": "C1, G2,C1", "reactions": ":taco:=2, +1=1,thumbsdown=-1", "replyStyle": "Reaction", "replyEmoji": ":raised_hands:", "locale": "fr_FR", "message.plus": "Nice one {{.User}}!", "message.plus.fr-CA": "Bravo {{.User}}!"} {
+		if err := s.Set(name, value); err != nil {
+			t.Errorf("should set %s: %v", name, err)
+		}
+	}
+
+	want := Limits{PerDay: 10, PerReceiver: 3, Cooldown: 30 * time.Second}
+	if !s.DisableMinusMinus || s.Limits() != want || s.AdminChannel != "G123" {
+		t.Errorf("should apply each setting, got %+v", s)
+	}
+	if len(s.ScanChannels) != 2 || !s.Scans("C1") || !s.Scans("G2") || s.Scans("C3") {
+		t.Errorf("should scan each channel listed once, got %v", s.ScanChannels)
+	}
+	if s.ReactionPoints("taco") != 2 || s.ReactionPoints("+1::skin-tone-3") != 1 || s.ReactionPoints("thumbsdown") != -1 || s.ReactionPoints("smile") != 0 {
+		t.Errorf("should give points for each reaction, got %v", s.Reactions)
+	}
+	if s.Replies() != ReplyReaction || s.Emoji() != "raised_hands" {
+		t.Errorf("should react to votes with the emoji, got %s %s", s.Replies(), s.Emoji())
+	}
+	if s.Locale != "fr-fr" || s.Messages["plus"] == "" || s.Messages["plus.fr-ca"] == "" {
+		t.Errorf("should set the locale and messages, got %s %v", s.Locale, s.Messages)
+	}
+	if err := s.Set("message.plus", ""); err != nil || len(s.Messages) != 1 {
+		t.Errorf("should revert a message to the default, got %v (%v)", s.Messages, err)
+	}
+	if (Settings{}).Replies() != ReplyThread || (Settings{}).Emoji() != DefaultReplyEmoji {
+		t.Error("should reply in threads by default")
+	}
+
+	for name, value := range map[string]string{"disableMinusMinus": "maybe", "dailyLimit": "-1", "cooldown": "soon", "adminChannel": "#admins", "scanChannels": "C1,#general", "reactions": "taco", "replyStyle": "shout", "replyEmoji": "party time", "locale": "French!", "message.plus": "{{.Points}}", "message.minus": "{{.User", "message.plus.!!": "hi", "message.hello": "hi", "colour": "blue"} {
+		if err := s.Set(name, value); err == nil {
+			t.Errorf("should reject %s=%s", name, value)
+		}
+	}
+}
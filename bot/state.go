@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StateTTL is how long an OAuth state value remains valid. It covers the time
+// a user spends on the Slack consent screen.
+const StateTTL = 10 * time.Minute
+
+// Errors returned when an OAuth state value fails verification.
+var (
+	ErrMissingState = errors.New("no OAuth state provided")
+	ErrInvalidState = errors.New("OAuth state is invalid")
+	ErrExpiredState = errors.New("OAuth state has expired")
+)
+
+// NewState returns a signed OAuth state value that expires after StateTTL. The
+// value is passed to Slack when an install starts and verified with VerifyState
+// when Slack redirects back to us.
+func (b *SlackBot) NewState() (string, error) {
+	payload := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, payload[:16]); err != nil {
+		return "", errors.Wrap(err, "unable to generate state")
+	}
+	binary.BigEndian.PutUint64(payload[16:], uint64(b.now().Add(StateTTL).Unix()))
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(b.signState(payload)), nil
+}
+
+// VerifyState returns an error unless state was issued by NewState and hasn't
+// expired.
+func (b *SlackBot) VerifyState(state string) error {
+	if state == "" {
+		return ErrMissingState
+	}
+
+	parts := strings.Split(state, ".")
+	if len(parts) != 2 {
+		return ErrInvalidState
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil || len(payload) != 24 {
+		return ErrInvalidState
+	}
+
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, b.signState(payload)) {
+		return ErrInvalidState
+	}
+
+	exp := time.Unix(int64(binary.BigEndian.Uint64(payload[16:])), 0)
+	if b.now().After(exp) {
+		return ErrExpiredState
+	}
+
+	return nil
+}
+
+// signState returns the HMAC of an OAuth state payload. The key is StateSecret
+// if set, otherwise one derived from the client secret.
+func (b *SlackBot) signState(payload []byte) []byte {
+	key := []byte(b.StateSecret)
+	if len(key) == 0 {
+		derive := hmac.New(sha256.New, []byte(b.ClientSecret))
+		derive.Write([]byte("buddybot-oauth-state"))
+		key = derive.Sum(nil)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
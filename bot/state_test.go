@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	b := &SlackBot{ClientSecret: "secret", Clock: func() time.Time { return now }}
+
+	state, err := b.NewState()
+	if err != nil {
+		t.Fatal("should create a state value:", err)
+	}
+
+	other, _ := b.NewState()
+	if state == other {
+		t.Error("should create a unique state value each time")
+	}
+
+	if err := b.VerifyState(state); err != nil {
+		t.Error("should accept a state value it issued:", err)
+	}
+
+	if err := b.VerifyState(""); err != ErrMissingState {
+		t.Error("should reject a missing state value, got:", err)
+	}
+
+	tampered := "A" + state[1:]
+	if tampered == state {
+		tampered = "B" + state[1:]
+	}
+	if err := b.VerifyState(tampered); err != ErrInvalidState {
+		t.Error("should reject a tampered state value, got:", err)
+	}
+
+	if err := (&SlackBot{ClientSecret: "other", Clock: b.Clock}).VerifyState(state); err != ErrInvalidState {
+		t.Error("should reject a state value signed with another key, got:", err)
+	}
+
+	if err := b.VerifyState(strings.Replace(state, ".", "", 1)); err != ErrInvalidState {
+		t.Error("should reject a malformed state value, got:", err)
+	}
+
+	now = now.Add(StateTTL + time.Second)
+	if err := b.VerifyState(state); err != ErrExpiredState {
+		t.Error("should reject an expired state value, got:", err)
+	}
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by a Store when the requested record doesn't exist.
+var ErrNotFound = errors.New("record not found")
+
+// Store is the persistence layer used by BuddyBot. It holds the install record
+// for each authenticated workspace, the settings chosen by each workspace, and
+// the PlusPlus score and awards for each user.
+type Store interface {
+	// GetAuth returns the install record with the given uid. It returns
+	// ErrNotFound if the workspace hasn't installed BuddyBot.
+	GetAuth(uid string) (AuthRecord, error)
+
+	// PutAuth stores an install record, replacing any existing record with
+	// the same uid.
+	PutAuth(rec AuthRecord) error
+
+	// ListAuth returns every install record.
+	ListAuth() ([]AuthRecord, error)
+
+	// DeleteAuth removes the install record with the given uid.
+	DeleteAuth(uid string) error
+
+	// IncrementScore adds delta to the score for a user in a team and returns
+	// the new score. Scores for a limited period are held on boards named by
+	// Board, and can be passed in place of the team.
+	IncrementScore(team, user string, delta int) (int, error)
+
+	// GetScore returns the current score for a user in a team. Users that have
+	// never been awarded points have a score of zero.
+	GetScore(team, user string) (int, error)
+
+	// SetScore replaces the score for a user in a team.
+	SetScore(team, user string, score int) error
+
+	// ForEachScore calls fn with every score held. It stops at the first
+	// error returned by fn. fn must not modify the store.
+	ForEachScore(fn func(team, user string, score int) error) error
+
+	// TopScores returns up to limit of the highest scores on a board, highest
+	// first.
+	TopScores(board string, limit int) ([]Score, error)
+
+	// Rank returns a user's score on a board and their rank, one more than the
+	// number of users with a higher score.
+	Rank(board, user string) (score, rank int, err error)
+
+	// DeleteScores removes every score held for a team, on every board, and
+	// returns the number of scores removed.
+	DeleteScores(team string) (int, error)
+
+	// AddAward appends an award made to a user to the ledger.
+	AddAward(a Award) error
+
+	// ForEachAward calls fn with every award in the ledger. It stops at the
+	// first error returned by fn. fn must not modify the store.
+	ForEachAward(fn func(a Award) error) error
+
+	// ListAwards returns up to limit of the awards made to a user in a team,
+	// most recent first.
+	ListAwards(team, user string, limit int) ([]Award, error)
+
+	// ListMessageAwards returns the awards made to a user in a team for a
+	// message, oldest first.
+	ListMessageAwards(team, user, messageTS string) ([]Award, error)
+
+	// DeleteAwards removes every award held for a team and returns the
+	// number of awards removed.
+	DeleteAwards(team string) (int, error)
+
+	// UseAllowance atomically checks the usage a giver has made of their
+	// allowance on the day that includes at against the limits given. If an
+	// award to receiver is allowed, it is recorded and the new usage is
+	// returned. Otherwise the current usage is returned with ErrOverLimit.
+	UseAllowance(team, giver, receiver string, at time.Time, l Limits) (Usage, error)
+
+	// ClaimEvent atomically records that the event with the given key is
+	// being processed at the time given, until expires. It returns
+	// ErrDuplicateEvent if there is already an unexpired claim on the key.
+	ClaimEvent(key string, at, expires time.Time) error
+
+	// ReleaseEvent removes the claim on the event with the given key.
+	ReleaseEvent(key string) error
+
+	// PutReply records the timestamp of the reply posted to the message with
+	// the given key, until expires.
+	PutReply(key, replyTS string, expires time.Time) error
+
+	// GetReply returns the timestamp of the reply posted to the message with
+	// the given key, if it hasn't expired at the time given. It returns
+	// ErrNotFound if there is no reply.
+	GetReply(key string, at time.Time) (string, error)
+
+	// DeleteReply removes the reply to the message with the given key.
+	DeleteReply(key string) error
+
+	// GetSettings returns the settings for a team. Teams that have never
+	// changed their settings get the defaults.
+	GetSettings(team string) (Settings, error)
+
+	// PutSettings stores the settings for a team, replacing any existing
+	// settings.
+	PutSettings(team string, s Settings) error
+
+	// DeleteSettings removes the settings held for a team.
+	DeleteSettings(team string) error
+}
+
+// newStore returns the Store selected by the "store" configuration key.
+// DynamoDB is used unless another store is requested.
+func newStore(c *Config) (Store, error) {
+	switch kind := c.Get(KeyStore); kind {
+
+	case "", "dynamodb":
+		tables := DynamoDBTables{
+			Auth:     c.Get(KeyAuthTable),
+			Score:    c.Get(KeyScoreTable),
+			Settings: c.Get(KeySettingsTable),
+			Award:    c.Get(KeyAwardTable),
+			Limit:    c.Get(KeyLimitTable),
+			Event:    c.Get(KeyEventTable),
+		}
+		return NewDynamoDBStore(c.Get(KeyRegion), tables)
+
+	case "memory":
+		return NewMemoryStore(), nil
+
+	case "bolt":
+		return NewBoltStore(c.Get(KeyStorePath))
+
+	default:
+		return nil, errors.Errorf("unknown store type '%s'", kind)
+	}
+}
+
+// newKeyProvider returns the KeyProvider selected by the "keyProvider"
+// configuration key, or nil if tokens shouldn't be encrypted.
+func newKeyProvider(c *Config) (KeyProvider, error) {
+	switch kind := c.Get(KeyKeyProvider); kind {
+	case "":
+		return nil, nil
+	case "kms":
+		return NewKMSKeyProvider(c.Get(KeyRegion), c.Get(KeyKMSKeyID))
+	case "local":
+		return NewLocalKeyProvider(c.Get(KeyKeyFile))
+	default:
+		return nil, errors.Errorf("unknown key provider '%s'", kind)
+	}
+}
+
+// scoreKey returns the key under which a user's score is stored.
+func scoreKey(team, user string) string {
+	return team + ":" + user
+}
+
+// boardPrefixes returns the prefixes of the keys for every score held for a
+// team, on every board. This includes the scores of things.
+func boardPrefixes(team string) []string {
+	return []string{scoreKey(team, ""), team + "@"}
+}
+
+// splitScoreKey returns the team and user a score key was made from.
+func splitScoreKey(k string) (team, user string) {
+	parts := strings.SplitN(k, ":", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
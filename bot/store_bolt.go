@@ -0,0 +1,539 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	authBucket     = []byte("auth")
+	scoreBucket    = []byte("score")
+	settingsBucket = []byte("settings")
+	awardBucket    = []byte("award")
+	allowedBucket  = []byte("allowance")
+	eventBucket    = []byte("event")
+	replyBucket    = []byte("reply")
+)
+
+// BoltStore is a Store backed by an embedded BoltDB file. It allows BuddyBot
+// to run on a single host without any external database.
+type BoltStore struct {
+	db    *bolt.DB
+	swept time.Time // when expired event claims and replies were last removed
+}
+
+// NewBoltStore opens, or creates, the BoltDB file at path. It returns an error
+// if the file can't be opened or the buckets can't be created.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open bolt database")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{authBucket, scoreBucket, settingsBucket, awardBucket, allowedBucket, eventBucket, replyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "unable to create buckets")
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the underlying database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// GetAuth returns the install record with the given uid.
+func (s *BoltStore) GetAuth(uid string) (AuthRecord, error) {
+	rec := AuthRecord{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(authBucket).Get([]byte(uid))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &rec)
+	})
+
+	return rec, err
+}
+
+// PutAuth stores an install record, replacing any existing record.
+func (s *BoltStore) PutAuth(rec AuthRecord) error {
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal record")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(authBucket).Put([]byte(rec.UID), v)
+	})
+}
+
+// ListAuth returns every install record.
+func (s *BoltStore) ListAuth() ([]AuthRecord, error) {
+	var recs []AuthRecord
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(authBucket).ForEach(func(k, v []byte) error {
+			rec := AuthRecord{}
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			recs = append(recs, rec)
+			return nil
+		})
+	})
+
+	return recs, err
+}
+
+// DeleteAuth removes the install record with the given uid.
+func (s *BoltStore) DeleteAuth(uid string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(authBucket).Delete([]byte(uid))
+	})
+}
+
+// IncrementScore adds delta to a user's score and returns the new score.
+func (s *BoltStore) IncrementScore(team, user string, delta int) (int, error) {
+	score := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scoreBucket)
+		k := []byte(scoreKey(team, user))
+
+		var err error
+		score, err = boltInt(b.Get(k))
+		if err != nil {
+			return err
+		}
+
+		score += delta
+		return b.Put(k, []byte(strconv.Itoa(score)))
+	})
+
+	return score, err
+}
+
+// GetScore returns the current score for a user.
+func (s *BoltStore) GetScore(team, user string) (int, error) {
+	score := 0
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		score, err = boltInt(tx.Bucket(scoreBucket).Get([]byte(scoreKey(team, user))))
+		return err
+	})
+
+	return score, err
+}
+
+// SetScore replaces the score for a user.
+func (s *BoltStore) SetScore(team, user string, score int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(scoreBucket).Put([]byte(scoreKey(team, user)), []byte(strconv.Itoa(score)))
+	})
+}
+
+// ForEachScore calls fn with every score held.
+func (s *BoltStore) ForEachScore(fn func(team, user string, score int) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(scoreBucket).ForEach(func(k, v []byte) error {
+			score, err := boltInt(v)
+			if err != nil {
+				return err
+			}
+			team, user := splitScoreKey(string(k))
+			return fn(team, user, score)
+		})
+	})
+}
+
+// TopScores returns up to limit of the highest scores on a board. Scores are
+// kept in key order, so only the scores on the board are read.
+func (s *BoltStore) TopScores(board string, limit int) ([]Score, error) {
+	var scores []Score
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return boltBoard(tx, board, func(user string, score int) {
+			scores = append(scores, Score{User: user, Score: score})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortScores(scores)
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+// Rank returns a user's score on a board and their rank.
+func (s *BoltStore) Rank(board, user string) (int, int, error) {
+	score, rank := 0, 1
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		score, err = boltInt(tx.Bucket(scoreBucket).Get([]byte(scoreKey(board, user))))
+		if err != nil {
+			return err
+		}
+
+		return boltBoard(tx, board, func(_ string, v int) {
+			if v > score {
+				rank++
+			}
+		})
+	})
+
+	return score, rank, err
+}
+
+// DeleteScores removes every score held for a team.
+func (s *BoltStore) DeleteScores(team string) (int, error) {
+	n := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, p := range boardPrefixes(team) {
+			prefix := []byte(p)
+			c := tx.Bucket(scoreBucket).Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// boltBoard calls fn with every score on a board.
+func boltBoard(tx *bolt.Tx, board string, fn func(user string, score int)) error {
+	prefix := []byte(scoreKey(board, ""))
+	c := tx.Bucket(scoreBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		score, err := boltInt(v)
+		if err != nil {
+			return err
+		}
+		fn(string(k[len(prefix):]), score)
+	}
+	return nil
+}
+
+// AddAward appends an award made to a user to the ledger.
+func (s *BoltStore) AddAward(a Award) error {
+	v, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal award")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(awardBucket).Put([]byte(awardKey(a)), v)
+	})
+}
+
+// ForEachAward calls fn with every award in the ledger.
+func (s *BoltStore) ForEachAward(fn func(a Award) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(awardBucket).ForEach(func(k, v []byte) error {
+			a := Award{}
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			return fn(a)
+		})
+	})
+}
+
+// ListAwards returns up to limit of the awards made to a user, most recent first.
+func (s *BoltStore) ListAwards(team, user string, limit int) ([]Award, error) {
+	awards := make([]Award, 0, limit)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(awardPrefix(team, user))
+		c := tx.Bucket(awardBucket).Cursor()
+
+		// start after the last key with the prefix and walk backwards
+		k, v := c.Seek(append(prefix, 0xff))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(awards) < limit; k, v = c.Prev() {
+			a := Award{}
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			awards = append(awards, a)
+		}
+		return nil
+	})
+
+	return awards, err
+}
+
+// ListMessageAwards returns the awards made to a user for a message, oldest
+// first.
+func (s *BoltStore) ListMessageAwards(team, user, messageTS string) ([]Award, error) {
+	var awards []Award
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(awardPrefix(team, user))
+		c := tx.Bucket(awardBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			a := Award{}
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			if a.MessageTS == messageTS {
+				awards = append(awards, a)
+			}
+		}
+		return nil
+	})
+
+	return awards, err
+}
+
+// DeleteAwards removes every award held for a team, including awards to
+// things.
+func (s *BoltStore) DeleteAwards(team string) (int, error) {
+	n := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, p := range boardPrefixes(team) {
+			prefix := []byte(p)
+			c := tx.Bucket(awardBucket).Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// UseAllowance checks and records an award against a giver's allowance.
+func (s *BoltStore) UseAllowance(team, giver, receiver string, at time.Time, l Limits) (Usage, error) {
+	u := Usage{}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(allowedBucket)
+		k := []byte(allowanceKey(team, giver))
+
+		a := allowance{}
+		if v := b.Get(k); v != nil {
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+		}
+		a = a.current(at)
+
+		u = a.usage(receiver)
+		if !allowed(u, at, l) {
+			return ErrOverLimit
+		}
+
+		a.use(receiver, at)
+		u = a.usage(receiver)
+
+		v, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return b.Put(k, v)
+	})
+
+	return u, err
+}
+
+// ClaimEvent records that an event is being processed, unless it already is.
+// Expired claims and replies are removed at most once every EventTTL.
+func (s *BoltStore) ClaimEvent(key string, at, expires time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventBucket)
+
+		if at.Sub(s.swept) > EventTTL {
+			if err := boltSweep(tx, at); err != nil {
+				return err
+			}
+			s.swept = at
+		}
+
+		if v := b.Get([]byte(key)); v != nil && !boltExpired(v, at) {
+			return ErrDuplicateEvent
+		}
+
+		v, err := expires.UTC().MarshalText()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), v)
+	})
+}
+
+// boltSweep removes the event claims and replies that have expired at the
+// time given. Keys are collected before they are deleted, as deleting under a
+// cursor moves it on to the next key and that key would be skipped.
+func boltSweep(tx *bolt.Tx, at time.Time) error {
+	var expired [][]byte
+	err := tx.Bucket(eventBucket).ForEach(func(k, v []byte) error {
+		if boltExpired(v, at) {
+			expired = append(expired, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := boltDelete(tx.Bucket(eventBucket), expired); err != nil {
+		return err
+	}
+
+	expired = nil
+	err = tx.Bucket(replyBucket).ForEach(func(k, v []byte) error {
+		r := reply{}
+		if err := json.Unmarshal(v, &r); err != nil || !r.Expires.After(at) {
+			expired = append(expired, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return boltDelete(tx.Bucket(replyBucket), expired)
+}
+
+// boltDelete removes the given keys from a bucket.
+func boltDelete(b *bolt.Bucket, keys [][]byte) error {
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltExpired reports whether the expiry time held in v is before at.
+func boltExpired(v []byte, at time.Time) bool {
+	var exp time.Time
+	if err := exp.UnmarshalText(v); err != nil {
+		return true
+	}
+	return !exp.After(at)
+}
+
+// ReleaseEvent removes the claim on an event.
+func (s *BoltStore) ReleaseEvent(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventBucket).Delete([]byte(key))
+	})
+}
+
+// PutReply records the reply posted to a message.
+func (s *BoltStore) PutReply(key, replyTS string, expires time.Time) error {
+	v, err := json.Marshal(reply{TS: replyTS, Expires: expires})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal reply")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(replyBucket).Put([]byte(key), v)
+	})
+}
+
+// GetReply returns the reply posted to a message.
+func (s *BoltStore) GetReply(key string, at time.Time) (string, error) {
+	r := reply{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(replyBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &r)
+	})
+	if err != nil {
+		return "", err
+	}
+	if !r.Expires.After(at) {
+		return "", ErrNotFound
+	}
+
+	return r.TS, nil
+}
+
+// DeleteReply removes the reply to a message.
+func (s *BoltStore) DeleteReply(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(replyBucket).Delete([]byte(key))
+	})
+}
+
+// GetSettings returns the settings for a team.
+func (s *BoltStore) GetSettings(team string) (Settings, error) {
+	settings := Settings{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(settingsBucket).Get([]byte(team))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &settings)
+	})
+
+	return settings, err
+}
+
+// PutSettings stores the settings for a team.
+func (s *BoltStore) PutSettings(team string, settings Settings) error {
+	v, err := json.Marshal(settings)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal settings")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(settingsBucket).Put([]byte(team), v)
+	})
+}
+
+// DeleteSettings removes the settings held for a team.
+func (s *BoltStore) DeleteSettings(team string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(settingsBucket).Delete([]byte(team))
+	})
+}
+
+// boltInt decodes an integer value stored by BoltStore. A nil value is zero.
+func boltInt(v []byte) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(v))
+}
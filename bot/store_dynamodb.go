@@ -0,0 +1,823 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/pkg/errors"
+)
+
+// scoreIndex is the global secondary index on the score table that orders the
+// scores on each board. It is keyed on "board" with a sort key of "score".
+const scoreIndex = "board-score-index"
+
+// DynamoDBTables names the tables used by a DynamoDBStore. Every table is
+// keyed on "uid". The award table also has a sort key, "id". Items in the
+// limit table expire using DynamoDB's time to live on "expires".
+type DynamoDBTables struct {
+	Auth     string
+	Score    string
+	Settings string
+	Award    string
+	Limit    string
+	Event    string
+}
+
+// DynamoDBStore is a Store backed by DynamoDB tables holding install records,
+// scores, awards, allowances, workspace settings and the events processed,
+// along with the replies posted to them.
+type DynamoDBStore struct {
+	ddb           *dynamodb.DynamoDB
+	authTable     string
+	scoreTable    string
+	settingsTable string
+	awardTable    string
+	limitTable    string
+	eventTable    string
+}
+
+// NewDynamoDBStore returns a Store that persists data to DynamoDB in the given
+// region. It returns an error if it is unable to create an AWS session.
+func NewDynamoDBStore(region string, tables DynamoDBTables) (*DynamoDBStore, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create session")
+	}
+
+	s := &DynamoDBStore{
+		ddb:           dynamodb.New(sess),
+		authTable:     tables.Auth,
+		scoreTable:    tables.Score,
+		settingsTable: tables.Settings,
+		awardTable:    tables.Award,
+		limitTable:    tables.Limit,
+		eventTable:    tables.Event,
+	}
+	return s, nil
+}
+
+// GetAuth returns the install record with the given uid.
+func (s *DynamoDBStore) GetAuth(uid string) (AuthRecord, error) {
+	rec := AuthRecord{}
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(s.authTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(uid)}},
+	}
+
+	result, err := s.ddb.GetItem(input)
+	if err != nil {
+		return rec, errors.Wrap(err, "unable to get item")
+	}
+
+	if len(result.Item) == 0 {
+		return rec, ErrNotFound
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &rec)
+	if err != nil {
+		return rec, errors.Wrap(err, "unable to unmarshal item")
+	}
+
+	return rec, nil
+}
+
+// PutAuth stores an install record, replacing any existing record.
+func (s *DynamoDBStore) PutAuth(rec AuthRecord) error {
+	payload, err := dynamodbattribute.MarshalMap(rec)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal record")
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      payload,
+		TableName: aws.String(s.authTable),
+	}
+
+	_, err = s.ddb.PutItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// ListAuth returns every install record. It scans the whole table so should
+// only be used by maintenance tasks.
+func (s *DynamoDBStore) ListAuth() ([]AuthRecord, error) {
+	var recs []AuthRecord
+	var err error
+
+	input := &dynamodb.ScanInput{TableName: aws.String(s.authTable)}
+	scanErr := s.ddb.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		var items []AuthRecord
+		err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			return false
+		}
+		recs = append(recs, items...)
+		return true
+	})
+	if scanErr != nil {
+		return nil, errors.Wrap(scanErr, "unable to scan table")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal items")
+	}
+
+	return recs, nil
+}
+
+// DeleteAuth removes the install record with the given uid.
+func (s *DynamoDBStore) DeleteAuth(uid string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.authTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(uid)}},
+	}
+
+	_, err := s.ddb.DeleteItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete item")
+	}
+
+	return nil
+}
+
+// IncrementScore atomically adds delta to a user's score and returns the new
+// score. The board is written alongside the score so the score appears in
+// scoreIndex.
+func (s *DynamoDBStore) IncrementScore(team, user string, delta int) (int, error) {
+	score := 0
+
+	input := &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":s": {N: aws.String(strconv.Itoa(delta))},
+			":b": {S: aws.String(team)},
+		},
+		TableName:        aws.String(s.scoreTable),
+		Key:              map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(scoreKey(team, user))}},
+		ReturnValues:     aws.String("UPDATED_NEW"),
+		UpdateExpression: aws.String("add score :s set board = :b"),
+	}
+
+	v, err := s.ddb.UpdateItem(input)
+	if err != nil {
+		return score, errors.Wrap(err, "unable to update database")
+	}
+
+	err = dynamodbattribute.Unmarshal(v.Attributes["score"], &score)
+	if err != nil {
+		return score, errors.Wrap(err, "unable to unmarshal return value")
+	}
+
+	return score, nil
+}
+
+// GetScore returns the current score for a user.
+func (s *DynamoDBStore) GetScore(team, user string) (int, error) {
+	score := 0
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(s.scoreTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(scoreKey(team, user))}},
+	}
+
+	result, err := s.ddb.GetItem(input)
+	if err != nil {
+		return score, errors.Wrap(err, "unable to get item")
+	}
+
+	if v, ok := result.Item["score"]; ok {
+		err = dynamodbattribute.Unmarshal(v, &score)
+		if err != nil {
+			return score, errors.Wrap(err, "unable to unmarshal score")
+		}
+	}
+
+	return score, nil
+}
+
+// SetScore replaces the score for a user.
+func (s *DynamoDBStore) SetScore(team, user string, score int) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.scoreTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"uid":   {S: aws.String(scoreKey(team, user))},
+			"board": {S: aws.String(team)},
+			"score": {N: aws.String(strconv.Itoa(score))},
+		},
+	}
+
+	_, err := s.ddb.PutItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// ForEachScore calls fn with every score held. It scans the whole score table
+// so should only be used by maintenance tasks.
+func (s *DynamoDBStore) ForEachScore(fn func(team, user string, score int) error) error {
+	var err error
+
+	input := &dynamodb.ScanInput{TableName: aws.String(s.scoreTable)}
+	scanErr := s.ddb.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			var score struct {
+				UID   string `json:"uid"`
+				Score int    `json:"score"`
+			}
+			err = dynamodbattribute.UnmarshalMap(item, &score)
+			if err != nil {
+				err = errors.Wrap(err, "unable to unmarshal item")
+				return false
+			}
+
+			team, user := splitScoreKey(score.UID)
+			err = fn(team, user, score.Score)
+			if err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if scanErr != nil {
+		return errors.Wrap(scanErr, "unable to scan table")
+	}
+
+	return err
+}
+
+// TopScores returns up to limit of the highest scores on a board. It queries
+// scoreIndex, so only reads the scores returned.
+func (s *DynamoDBStore) TopScores(board string, limit int) ([]Score, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.scoreTable),
+		IndexName:                 aws.String(scoreIndex),
+		KeyConditionExpression:    aws.String("board = :b"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":b": {S: aws.String(board)}},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	result, err := s.ddb.Query(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query index")
+	}
+
+	var items []struct {
+		UID   string `json:"uid"`
+		Score int    `json:"score"`
+	}
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal items")
+	}
+
+	scores := make([]Score, 0, len(items))
+	for _, item := range items {
+		_, user := splitScoreKey(item.UID)
+		scores = append(scores, Score{User: user, Score: item.Score})
+	}
+	sortScores(scores)
+	return scores, nil
+}
+
+// Rank returns a user's score on a board and their rank. It counts the higher
+// scores in scoreIndex rather than reading them.
+func (s *DynamoDBStore) Rank(board, user string) (int, int, error) {
+	score, err := s.GetScore(board, user)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rank := 1
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.scoreTable),
+		IndexName:              aws.String(scoreIndex),
+		KeyConditionExpression: aws.String("board = :b AND score > :s"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":b": {S: aws.String(board)},
+			":s": {N: aws.String(strconv.Itoa(score))},
+		},
+		Select: aws.String(dynamodb.SelectCount),
+	}
+	err = s.ddb.QueryPages(input, func(page *dynamodb.QueryOutput, last bool) bool {
+		rank += int(aws.Int64Value(page.Count))
+		return true
+	})
+	if err != nil {
+		return score, 0, errors.Wrap(err, "unable to query index")
+	}
+
+	return score, rank, nil
+}
+
+// DeleteScores removes every score held for a team. It scans the whole score
+// table so should only be used by maintenance tasks.
+func (s *DynamoDBStore) DeleteScores(team string) (int, error) {
+	var keys []string
+
+	prefixes := boardPrefixes(team)
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(s.scoreTable),
+		ProjectionExpression: aws.String("uid"),
+		FilterExpression:     aws.String("begins_with(uid, :t) OR begins_with(uid, :p)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":t": {S: aws.String(prefixes[0])},
+			":p": {S: aws.String(prefixes[1])},
+		},
+	}
+	err := s.ddb.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			keys = append(keys, *item["uid"].S)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to scan table")
+	}
+
+	for i, k := range keys {
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.scoreTable),
+			Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(k)}},
+		}
+
+		_, err := s.ddb.DeleteItem(input)
+		if err != nil {
+			return i, errors.Wrap(err, "unable to delete item")
+		}
+	}
+
+	return len(keys), nil
+}
+
+// AddAward appends an award made to a user to the ledger.
+func (s *DynamoDBStore) AddAward(a Award) error {
+	payload, err := dynamodbattribute.MarshalMap(a)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal award")
+	}
+	payload["uid"] = &dynamodb.AttributeValue{S: aws.String(scoreKey(a.Team, a.Receiver))}
+	payload["id"] = &dynamodb.AttributeValue{S: aws.String(awardID(a))}
+	if a.MessageTS != "" {
+		payload["message"] = &dynamodb.AttributeValue{S: aws.String(messageKey(a.Team, a.Receiver, a.MessageTS))}
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      payload,
+		TableName: aws.String(s.awardTable),
+	}
+
+	_, err = s.ddb.PutItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// ForEachAward calls fn with every award in the ledger. It scans the whole
+// award table so should only be used by maintenance tasks.
+func (s *DynamoDBStore) ForEachAward(fn func(a Award) error) error {
+	var err error
+
+	input := &dynamodb.ScanInput{TableName: aws.String(s.awardTable)}
+	scanErr := s.ddb.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		var awards []Award
+		err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &awards)
+		if err != nil {
+			err = errors.Wrap(err, "unable to unmarshal items")
+			return false
+		}
+
+		for _, a := range awards {
+			err = fn(a)
+			if err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if scanErr != nil {
+		return errors.Wrap(scanErr, "unable to scan table")
+	}
+
+	return err
+}
+
+// ListAwards returns up to limit of the awards made to a user, most recent first.
+func (s *DynamoDBStore) ListAwards(team, user string, limit int) ([]Award, error) {
+	var awards []Award
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.awardTable),
+		KeyConditionExpression:    aws.String("uid = :u"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":u": {S: aws.String(scoreKey(team, user))}},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	result, err := s.ddb.Query(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query table")
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &awards)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal items")
+	}
+
+	return awards, nil
+}
+
+// ListMessageAwards returns the awards made to a user for a message, oldest
+// first. Awards are looked up by message in the awardMessageIndex, so only
+// the awards for the message are read.
+func (s *DynamoDBStore) ListMessageAwards(team, user, messageTS string) ([]Award, error) {
+	var awards []Award
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(s.awardTable),
+		IndexName:                aws.String(awardMessageIndex),
+		KeyConditionExpression:   aws.String("#m = :m"),
+		ExpressionAttributeNames: map[string]*string{"#m": aws.String("message")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":m": {S: aws.String(messageKey(team, user, messageTS))},
+		},
+	}
+
+	var err error
+	queryErr := s.ddb.QueryPages(input, func(page *dynamodb.QueryOutput, last bool) bool {
+		var as []Award
+		err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &as)
+		if err != nil {
+			err = errors.Wrap(err, "unable to unmarshal items")
+			return false
+		}
+		awards = append(awards, as...)
+		return true
+	})
+	if queryErr != nil {
+		return nil, errors.Wrap(queryErr, "unable to query index")
+	}
+
+	return awards, err
+}
+
+// awardMessageIndex is the global secondary index on the award table keyed by
+// message, with the same sort key as the table. Only awards made for a
+// message are indexed.
+const awardMessageIndex = "message-index"
+
+// messageKey returns the key under which the awards made to a user for a
+// message are indexed.
+func messageKey(team, user, messageTS string) string {
+	return scoreKey(team, user) + "#" + messageTS
+}
+
+// DeleteAwards removes every award held for a team, including awards to
+// things. It scans the whole award table so should only be used by
+// maintenance tasks.
+func (s *DynamoDBStore) DeleteAwards(team string) (int, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+
+	prefixes := boardPrefixes(team)
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(s.awardTable),
+		ProjectionExpression: aws.String("uid, id"),
+		FilterExpression:     aws.String("begins_with(uid, :t) OR begins_with(uid, :p)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":t": {S: aws.String(prefixes[0])},
+			":p": {S: aws.String(prefixes[1])},
+		},
+	}
+	err := s.ddb.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		keys = append(keys, page.Items...)
+		return true
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to scan table")
+	}
+
+	for i, k := range keys {
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.awardTable),
+			Key:       k,
+		}
+
+		_, err := s.ddb.DeleteItem(input)
+		if err != nil {
+			return i, errors.Wrap(err, "unable to delete item")
+		}
+	}
+
+	return len(keys), nil
+}
+
+// UseAllowance checks and records an award against a giver's allowance. The
+// limits are checked by a condition on the update, so concurrent awards can't
+// both use the last of an allowance. A giver's usage is kept in one item,
+// which is replaced when their first award after the window has passed starts
+// it afresh, and expires once it is no longer needed.
+func (s *DynamoDBStore) UseAllowance(team, giver, receiver string, at time.Time, l Limits) (Usage, error) {
+	key := map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(allowanceKey(team, giver))}}
+
+	names := map[string]*string{
+		"#r": aws.String("to_" + receiver),
+		"#l": aws.String("last"),
+		"#s": aws.String("start"),
+		"#e": aws.String("expires"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":one": {N: aws.String("1")},
+		":now": {N: aws.String(strconv.FormatInt(at.UnixNano(), 10))},
+		":ws":  {N: aws.String(strconv.FormatInt(at.Add(-AllowanceWindow).UnixNano(), 10))},
+		":exp": {N: aws.String(strconv.FormatInt(at.Add(2*AllowanceWindow).Unix(), 10))},
+	}
+
+	var limits []string
+	if l.PerDay > 0 {
+		limits = append(limits, "(attribute_not_exists(given) OR given < :pd)")
+		values[":pd"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(l.PerDay))}
+	}
+	if l.PerReceiver > 0 {
+		limits = append(limits, "(attribute_not_exists(#r) OR #r < :pr)")
+		values[":pr"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(l.PerReceiver))}
+	}
+	cooldown := ""
+	if l.Cooldown > 0 {
+		cooldown = "(attribute_not_exists(#l) OR #l <= :cut)"
+		limits = append(limits, cooldown)
+		values[":cut"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(at.Add(-l.Cooldown).UnixNano(), 10))}
+	}
+
+	// a concurrent award may start the window afresh between our attempts,
+	// so try again once if it does
+	for attempt := 0; attempt < 2; attempt++ {
+		// use the current window, if it hasn't passed
+		update := &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.limitTable),
+			Key:                       key,
+			UpdateExpression:          aws.String("ADD given :one, #r :one SET #l = :now, #e = :exp"),
+			ConditionExpression:       aws.String(strings.Join(append([]string{"#s > :ws"}, limits...), " AND ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ReturnValues:              aws.String("ALL_NEW"),
+		}
+		result, err := s.ddb.UpdateItem(update)
+		if err == nil {
+			return dynamoUsage(result.Attributes, receiver, at), nil
+		}
+		if !conditionFailed(err) {
+			return Usage{}, errors.Wrap(err, "unable to update database")
+		}
+
+		current, err := s.ddb.GetItem(&dynamodb.GetItemInput{TableName: aws.String(s.limitTable), Key: key})
+		if err != nil {
+			return Usage{}, errors.Wrap(err, "unable to get item")
+		}
+		u := dynamoUsage(current.Item, receiver, at)
+		if !u.Start.IsZero() {
+			return u, ErrOverLimit
+		}
+
+		// otherwise start a new window, as long as the cooldown allows
+		condition := "(attribute_not_exists(#s) OR #s <= :ws)"
+		if cooldown != "" {
+			condition += " AND " + cooldown
+		}
+		put := &dynamodb.PutItemInput{
+			TableName: aws.String(s.limitTable),
+			Item: map[string]*dynamodb.AttributeValue{
+				"uid":            key["uid"],
+				"given":          values[":one"],
+				"to_" + receiver: values[":one"],
+				"last":           values[":now"],
+				"start":          values[":now"],
+				"expires":        values[":exp"],
+			},
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  map[string]*string{"#s": names["#s"], "#l": names["#l"]},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":ws": values[":ws"]},
+		}
+		if cooldown != "" {
+			put.ExpressionAttributeValues[":cut"] = values[":cut"]
+		} else {
+			delete(put.ExpressionAttributeNames, "#l")
+		}
+
+		_, err = s.ddb.PutItem(put)
+		if err == nil {
+			return Usage{Given: 1, GivenTo: 1, Last: at, Start: at}, nil
+		}
+		if !conditionFailed(err) {
+			return Usage{}, errors.Wrap(err, "unable to put item")
+		}
+		if l.Cooldown > 0 && u.Last.After(at.Add(-l.Cooldown)) {
+			return u, ErrOverLimit
+		}
+	}
+
+	return Usage{}, errors.New("unable to start a new allowance")
+}
+
+// conditionFailed reports whether err is the failure of a condition on a
+// write.
+func conditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// dynamoUsage reads the usage of an allowance at the time given from a limit
+// table item. Usage from a window that has passed is ignored, apart from the
+// time of the last award.
+func dynamoUsage(item map[string]*dynamodb.AttributeValue, receiver string, at time.Time) Usage {
+	n := func(name string) int64 {
+		v, ok := item[name]
+		if !ok || v.N == nil {
+			return 0
+		}
+		i, _ := strconv.ParseInt(*v.N, 10, 64)
+		return i
+	}
+
+	a := allowance{Given: int(n("given")), Receivers: map[string]int{receiver: int(n("to_" + receiver))}}
+	if last := n("last"); last != 0 {
+		a.Last = time.Unix(0, last)
+	}
+	if start := n("start"); start != 0 {
+		a.Start = time.Unix(0, start)
+	}
+	return a.current(at).usage(receiver)
+}
+
+// ClaimEvent records that an event is being processed, unless it already is.
+// Claims are removed once they expire by the table's time to live, which may
+// take a while, so expired claims that remain are ignored.
+func (s *DynamoDBStore) ClaimEvent(key string, at, expires time.Time) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.eventTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"uid":     {S: aws.String(key)},
+			"expires": {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(uid) OR expires <= :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(at.Unix(), 10))},
+		},
+	}
+
+	_, err := s.ddb.PutItem(input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrDuplicateEvent
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// ReleaseEvent removes the claim on an event.
+func (s *DynamoDBStore) ReleaseEvent(key string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.eventTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(key)}},
+	}
+
+	_, err := s.ddb.DeleteItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete item")
+	}
+
+	return nil
+}
+
+// PutReply records the reply posted to a message. Replies are kept in the
+// event table.
+func (s *DynamoDBStore) PutReply(key, replyTS string, expires time.Time) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.eventTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"uid":     {S: aws.String(key)},
+			"reply":   {S: aws.String(replyTS)},
+			"expires": {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+		},
+	}
+
+	_, err := s.ddb.PutItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// GetReply returns the reply posted to a message.
+func (s *DynamoDBStore) GetReply(key string, at time.Time) (string, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(s.eventTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(key)}},
+	}
+
+	result, err := s.ddb.GetItem(input)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get item")
+	}
+
+	ts, ok := result.Item["reply"]
+	if !ok || ts.S == nil {
+		return "", ErrNotFound
+	}
+
+	// items aren't removed as soon as they expire
+	if exp, ok := result.Item["expires"]; ok && exp.N != nil {
+		if n, _ := strconv.ParseInt(*exp.N, 10, 64); n <= at.Unix() {
+			return "", ErrNotFound
+		}
+	}
+
+	return *ts.S, nil
+}
+
+// DeleteReply removes the reply to a message.
+func (s *DynamoDBStore) DeleteReply(key string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.eventTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(key)}},
+	}
+
+	_, err := s.ddb.DeleteItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete item")
+	}
+
+	return nil
+}
+
+// GetSettings returns the settings for a team.
+func (s *DynamoDBStore) GetSettings(team string) (Settings, error) {
+	settings := Settings{}
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(s.settingsTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(team)}},
+	}
+
+	result, err := s.ddb.GetItem(input)
+	if err != nil {
+		return settings, errors.Wrap(err, "unable to get item")
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &settings)
+	if err != nil {
+		return settings, errors.Wrap(err, "unable to unmarshal item")
+	}
+
+	return settings, nil
+}
+
+// PutSettings stores the settings for a team, replacing any existing settings.
+func (s *DynamoDBStore) PutSettings(team string, settings Settings) error {
+	payload, err := dynamodbattribute.MarshalMap(settings)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal settings")
+	}
+	payload["uid"] = &dynamodb.AttributeValue{S: aws.String(team)}
+
+	input := &dynamodb.PutItemInput{
+		Item:      payload,
+		TableName: aws.String(s.settingsTable),
+	}
+
+	_, err = s.ddb.PutItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to put item")
+	}
+
+	return nil
+}
+
+// DeleteSettings removes the settings held for a team.
+func (s *DynamoDBStore) DeleteSettings(team string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.settingsTable),
+		Key:       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(team)}},
+	}
+
+	_, err := s.ddb.DeleteItem(input)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete item")
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// EncryptedStore wraps a Store so that workspace tokens are encrypted before
+// they are written and decrypted when they are read. Records written before
+// encryption was enabled are returned unchanged until they are re-encrypted.
+type EncryptedStore struct {
+	Store
+	Keys KeyProvider
+}
+
+// GetAuth returns the install record with the given uid, with tokens decrypted.
+func (s *EncryptedStore) GetAuth(uid string) (AuthRecord, error) {
+	rec, err := s.Store.GetAuth(uid)
+	if err != nil {
+		return rec, err
+	}
+	return s.decrypt(rec)
+}
+
+// PutAuth encrypts the tokens in rec under a new data key and stores the record.
+func (s *EncryptedStore) PutAuth(rec AuthRecord) error {
+	rec, err := s.encrypt(rec)
+	if err != nil {
+		return err
+	}
+	return s.Store.PutAuth(rec)
+}
+
+// ListAuth returns every install record, with tokens decrypted.
+func (s *EncryptedStore) ListAuth() ([]AuthRecord, error) {
+	recs, err := s.Store.ListAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range recs {
+		recs[i], err = s.decrypt(recs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return recs, nil
+}
+
+// Reencrypt re-encrypts every install record that is stored in plaintext or
+// under a master key other than the current one. It returns the number of
+// records updated.
+func (s *EncryptedStore) Reencrypt() (int, error) {
+	recs, err := s.Store.ListAuth()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, rec := range recs {
+		if rec.KeyID == s.Keys.KeyID() {
+			continue
+		}
+
+		rec, err = s.decrypt(rec)
+		if err != nil {
+			return n, err
+		}
+
+		err = s.PutAuth(rec)
+		if err != nil {
+			return n, errors.Wrapf(err, "unable to re-encrypt record '%s'", rec.UID)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *EncryptedStore) encrypt(rec AuthRecord) (AuthRecord, error) {
+	key, wrapped, err := s.Keys.GenerateDataKey()
+	if err != nil {
+		return rec, err
+	}
+
+	// the uid is bound to each token so tokens can't be swapped between records
+	for _, token := range tokens(&rec) {
+		if *token == "" {
+			continue
+		}
+		ct, err := seal(key, []byte(*token), []byte(rec.UID))
+		if err != nil {
+			return rec, errors.Wrap(err, "unable to encrypt token")
+		}
+		*token = base64.StdEncoding.EncodeToString(ct)
+	}
+
+	rec.KeyID = s.Keys.KeyID()
+	rec.DataKey = base64.StdEncoding.EncodeToString(wrapped)
+	return rec, nil
+}
+
+func (s *EncryptedStore) decrypt(rec AuthRecord) (AuthRecord, error) {
+	if rec.KeyID == "" {
+		return rec, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(rec.DataKey)
+	if err != nil {
+		return rec, errors.Wrap(err, "unable to decode data key")
+	}
+
+	key, err := s.Keys.DecryptDataKey(rec.KeyID, wrapped)
+	if err != nil {
+		return rec, err
+	}
+
+	for _, token := range tokens(&rec) {
+		if *token == "" {
+			continue
+		}
+		ct, err := base64.StdEncoding.DecodeString(*token)
+		if err != nil {
+			return rec, errors.Wrap(err, "unable to decode token")
+		}
+
+		pt, err := open(key, ct, []byte(rec.UID))
+		if err != nil {
+			return rec, errors.Wrap(err, "unable to decrypt token")
+		}
+		*token = string(pt)
+	}
+
+	rec.KeyID = ""
+	rec.DataKey = ""
+	return rec, nil
+}
+
+// tokens returns pointers to every secret held in rec. Empty tokens are left
+// empty when a record is encrypted.
+func tokens(rec *AuthRecord) []*string {
+	return []*string{&rec.AccessToken, &rec.RefreshToken, &rec.BotAccessToken, &rec.BotRefreshToken}
+}
@@ -0,0 +1,356 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that holds everything in memory. Data is lost when
+// the process exits, which makes it suitable for tests and local development.
+type MemoryStore struct {
+	mu       sync.Mutex
+	auth     map[string]AuthRecord
+	scores   map[string]int
+	settings map[string]Settings
+	awards   map[string][]Award
+	allowed  map[string]allowance
+	events   map[string]time.Time
+	replies  map[string]reply
+	swept    time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		auth:     make(map[string]AuthRecord),
+		scores:   make(map[string]int),
+		settings: make(map[string]Settings),
+		awards:   make(map[string][]Award),
+		allowed:  make(map[string]allowance),
+		events:   make(map[string]time.Time),
+		replies:  make(map[string]reply),
+	}
+}
+
+// GetAuth returns the install record with the given uid.
+func (s *MemoryStore) GetAuth(uid string) (AuthRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.auth[uid]
+	if !ok {
+		return rec, ErrNotFound
+	}
+	return rec, nil
+}
+
+// PutAuth stores an install record, replacing any existing record.
+func (s *MemoryStore) PutAuth(rec AuthRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auth[rec.UID] = rec
+	return nil
+}
+
+// ListAuth returns every install record.
+func (s *MemoryStore) ListAuth() ([]AuthRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := make([]AuthRecord, 0, len(s.auth))
+	for _, rec := range s.auth {
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// DeleteAuth removes the install record with the given uid.
+func (s *MemoryStore) DeleteAuth(uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.auth, uid)
+	return nil
+}
+
+// IncrementScore adds delta to a user's score and returns the new score.
+func (s *MemoryStore) IncrementScore(team, user string, delta int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := scoreKey(team, user)
+	s.scores[k] += delta
+	return s.scores[k], nil
+}
+
+// GetScore returns the current score for a user.
+func (s *MemoryStore) GetScore(team, user string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.scores[scoreKey(team, user)], nil
+}
+
+// SetScore replaces the score for a user.
+func (s *MemoryStore) SetScore(team, user string, score int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scores[scoreKey(team, user)] = score
+	return nil
+}
+
+// ForEachScore calls fn with every score held.
+func (s *MemoryStore) ForEachScore(fn func(team, user string, score int) error) error {
+	s.mu.Lock()
+	scores := make(map[string]int, len(s.scores))
+	for k, v := range s.scores {
+		scores[k] = v
+	}
+	s.mu.Unlock()
+
+	for k, v := range scores {
+		team, user := splitScoreKey(k)
+		if err := fn(team, user, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TopScores returns up to limit of the highest scores on a board.
+func (s *MemoryStore) TopScores(board string, limit int) ([]Score, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var scores []Score
+	for k, v := range s.scores {
+		if b, user := splitScoreKey(k); b == board {
+			scores = append(scores, Score{User: user, Score: v})
+		}
+	}
+
+	sortScores(scores)
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+// Rank returns a user's score on a board and their rank.
+func (s *MemoryStore) Rank(board, user string) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	score := s.scores[scoreKey(board, user)]
+	rank := 1
+	for k, v := range s.scores {
+		if b, _ := splitScoreKey(k); b == board && v > score {
+			rank++
+		}
+	}
+	return score, rank, nil
+}
+
+// DeleteScores removes every score held for a team.
+func (s *MemoryStore) DeleteScores(team string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for k := range s.scores {
+		for _, prefix := range boardPrefixes(team) {
+			if strings.HasPrefix(k, prefix) {
+				delete(s.scores, k)
+				n++
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// AddAward appends an award made to a user to the ledger.
+func (s *MemoryStore) AddAward(a Award) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := scoreKey(a.Team, a.Receiver)
+	s.awards[k] = append(s.awards[k], a)
+	return nil
+}
+
+// ForEachAward calls fn with every award in the ledger.
+func (s *MemoryStore) ForEachAward(fn func(a Award) error) error {
+	s.mu.Lock()
+	var awards []Award
+	for _, a := range s.awards {
+		awards = append(awards, a...)
+	}
+	s.mu.Unlock()
+
+	for _, a := range awards {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAwards returns up to limit of the awards made to a user, most recent first.
+func (s *MemoryStore) ListAwards(team, user string, limit int) ([]Award, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := s.awards[scoreKey(team, user)]
+	awards := make([]Award, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(awards) < limit; i-- {
+		awards = append(awards, all[i])
+	}
+	return awards, nil
+}
+
+// ListMessageAwards returns the awards made to a user for a message, oldest
+// first.
+func (s *MemoryStore) ListMessageAwards(team, user, messageTS string) ([]Award, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var awards []Award
+	for _, a := range s.awards[scoreKey(team, user)] {
+		if a.MessageTS == messageTS {
+			awards = append(awards, a)
+		}
+	}
+	return awards, nil
+}
+
+// DeleteAwards removes every award held for a team, including awards to
+// things.
+func (s *MemoryStore) DeleteAwards(team string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for k, awards := range s.awards {
+		for _, prefix := range boardPrefixes(team) {
+			if strings.HasPrefix(k, prefix) {
+				delete(s.awards, k)
+				n += len(awards)
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// UseAllowance checks and records an award against a giver's allowance.
+func (s *MemoryStore) UseAllowance(team, giver, receiver string, at time.Time, l Limits) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := allowanceKey(team, giver)
+	a := s.allowed[k].current(at)
+	if !allowed(a.usage(receiver), at, l) {
+		return a.usage(receiver), ErrOverLimit
+	}
+
+	a.use(receiver, at)
+	s.allowed[k] = a
+	return a.usage(receiver), nil
+}
+
+// ClaimEvent records that an event is being processed, unless it already is.
+// Expired claims and replies are removed at most once every EventTTL, so that
+// a long running server doesn't keep them forever.
+func (s *MemoryStore) ClaimEvent(key string, at, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if at.Sub(s.swept) > EventTTL {
+		for k, exp := range s.events {
+			if !exp.After(at) {
+				delete(s.events, k)
+			}
+		}
+		for k, r := range s.replies {
+			if !r.Expires.After(at) {
+				delete(s.replies, k)
+			}
+		}
+		s.swept = at
+	}
+
+	if exp, ok := s.events[key]; ok && exp.After(at) {
+		return ErrDuplicateEvent
+	}
+	s.events[key] = expires
+	return nil
+}
+
+// ReleaseEvent removes the claim on an event.
+func (s *MemoryStore) ReleaseEvent(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, key)
+	return nil
+}
+
+// PutReply records the reply posted to a message.
+func (s *MemoryStore) PutReply(key, replyTS string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies[key] = reply{TS: replyTS, Expires: expires}
+	return nil
+}
+
+// GetReply returns the reply posted to a message.
+func (s *MemoryStore) GetReply(key string, at time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.replies[key]
+	if !ok || !r.Expires.After(at) {
+		return "", ErrNotFound
+	}
+	return r.TS, nil
+}
+
+// DeleteReply removes the reply to a message.
+func (s *MemoryStore) DeleteReply(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.replies, key)
+	return nil
+}
+
+// GetSettings returns the settings for a team.
+func (s *MemoryStore) GetSettings(team string) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.settings[team], nil
+}
+
+// PutSettings stores the settings for a team.
+func (s *MemoryStore) PutSettings(team string, settings Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[team] = settings
+	return nil
+}
+
+// DeleteSettings removes the settings held for a team.
+func (s *MemoryStore) DeleteSettings(team string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.settings, team)
+	return nil
+}
@@ -0,0 +1,430 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewBoltStore(filepath.Join(dir, "buddybot.db"))
+	if err != nil {
+		t.Fatal("unable to open store:", err)
+	}
+	defer s.Close()
+
+	testStore(t, s)
+}
+
+func TestBoltStoreSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddybot")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewBoltStore(filepath.Join(dir, "buddybot.db"))
+	if err != nil {
+		t.Fatal("unable to open store:", err)
+	}
+	defer s.Close()
+
+	at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	for _, k := range []string{"Ev1:U1", "Ev1:U2", "Ev1:U3", "Ev2:U1"} {
+		s.ClaimEvent(k, at, at.Add(time.Hour))
+		s.PutReply("reply:T123:C1:"+k, "1.2", at.Add(time.Hour))
+	}
+
+	later := at.Add(EventTTL + 2*time.Hour)
+	if err := s.ClaimEvent("Ev3:U1", later, later.Add(time.Hour)); err != nil {
+		t.Fatal("should claim the event:", err)
+	}
+
+	s.db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket(eventBucket).Stats().KeyN; n != 1 {
+			t.Error("should sweep every expired claim, got:", n)
+		}
+		if n := tx.Bucket(replyBucket).Stats().KeyN; n != 0 {
+			t.Error("should sweep every expired reply, got:", n)
+		}
+		return nil
+	})
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+
+	at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	for _, k := range []string{"Ev1:U1", "Ev1:U2", "Ev2:U1"} {
+		s.ClaimEvent(k, at, at.Add(time.Hour))
+		s.PutReply("reply:T123:C1:"+k, "1.2", at.Add(time.Hour))
+	}
+
+	later := at.Add(EventTTL + 2*time.Hour)
+	if err := s.ClaimEvent("Ev3:U1", later, later.Add(time.Hour)); err != nil {
+		t.Fatal("should claim the event:", err)
+	}
+
+	if len(s.events) != 1 {
+		t.Error("should sweep every expired claim, got:", len(s.events))
+	}
+	if len(s.replies) != 0 {
+		t.Error("should sweep every expired reply, got:", len(s.replies))
+	}
+}
+
+// testStore exercises the behaviour every Store implementation should share.
+func testStore(t *testing.T, s Store) {
+	t.Run("missing auth record", func(t *testing.T) {
+		_, err := s.GetAuth("T000")
+		if err != ErrNotFound {
+			t.Error("should return ErrNotFound, got:", err)
+		}
+	})
+
+	t.Run("auth record round trip", func(t *testing.T) {
+		rec := AuthRecord{UID: "T123", TeamID: "T123", BotAccessToken: "xoxb-1"}
+		if err := s.PutAuth(rec); err != nil {
+			t.Fatal("should store the record:", err)
+		}
+
+		got, err := s.GetAuth("T123")
+		if err != nil {
+			t.Fatal("should retrieve the record:", err)
+		}
+		if !reflect.DeepEqual(got, rec) {
+			t.Errorf("should return the stored record, got %+v", got)
+		}
+
+		recs, err := s.ListAuth()
+		if err != nil || len(recs) != 1 || !reflect.DeepEqual(recs[0], rec) {
+			t.Errorf("should list the stored record, got %+v (%v)", recs, err)
+		}
+	})
+
+	t.Run("scores", func(t *testing.T) {
+		score, err := s.GetScore("T123", "U1")
+		if err != nil || score != 0 {
+			t.Errorf("should start at zero, got %d (%v)", score, err)
+		}
+
+		s.IncrementScore("T123", "U1", 1)
+		score, err = s.IncrementScore("T123", "U1", 2)
+		if err != nil || score != 3 {
+			t.Errorf("should return the new score, got %d (%v)", score, err)
+		}
+
+		score, err = s.GetScore("T123", "U1")
+		if err != nil || score != 3 {
+			t.Errorf("should return the stored score, got %d (%v)", score, err)
+		}
+
+		score, _ = s.GetScore("T999", "U1")
+		if score != 0 {
+			t.Error("should keep scores separate per team")
+		}
+
+		s.SetScore("T999", "U1", 7)
+		all := make(map[string]int)
+		err = s.ForEachScore(func(team, user string, score int) error {
+			all[team+"/"+user] = score
+			return nil
+		})
+		if err != nil || len(all) != 2 || all["T123/U1"] != 3 || all["T999/U1"] != 7 {
+			t.Errorf("should visit every score, got %v (%v)", all, err)
+		}
+		s.DeleteScores("T999")
+
+		s.IncrementScore("T123", "U2", 1)
+		s.IncrementScore("T1234", "U1", 1)
+		n, err := s.DeleteScores("T123")
+		if err != nil || n != 2 {
+			t.Errorf("should delete both scores for the team, got %d (%v)", n, err)
+		}
+
+		score, _ = s.GetScore("T1234", "U1")
+		if score != 1 {
+			t.Error("should not delete scores for other teams")
+		}
+	})
+
+	t.Run("boards", func(t *testing.T) {
+		week := Board("T123", Week, time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC))
+		for user, score := range map[string]int{"U1": 3, "U2": 7, "U3": 3, "U4": -1} {
+			s.IncrementScore(week, user, score)
+		}
+		s.IncrementScore("T123", "U9", 100)
+
+		top, err := s.TopScores(week, 3)
+		want := []Score{{"U2", 7}, {"U1", 3}, {"U3", 3}}
+		if err != nil || !reflect.DeepEqual(top, want) {
+			t.Errorf("should return the highest scores on the board, got %v (%v)", top, err)
+		}
+
+		for user, want := range map[string][2]int{"U2": {7, 1}, "U3": {3, 2}, "U4": {-1, 4}, "U5": {0, 4}} {
+			score, rank, err := s.Rank(week, user)
+			if err != nil || score != want[0] || rank != want[1] {
+				t.Errorf("should rank %s at %v, got %d, %d (%v)", user, want, score, rank, err)
+			}
+		}
+
+		n, err := s.DeleteScores("T123")
+		if err != nil || n != 5 {
+			t.Errorf("should delete scores on every board for the team, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("awards", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+		for i, r := range []string{"first", "second", "third"} {
+			a := Award{Team: "T123", Receiver: "U1", Giver: "U2", Amount: 1, Reason: r, MessageTS: r, At: at.Add(time.Duration(i) * time.Minute)}
+			if err := s.AddAward(a); err != nil {
+				t.Fatal("should store the award:", err)
+			}
+		}
+		s.AddAward(Award{Team: "T123", Receiver: "U12", Giver: "U2", Amount: 1, Reason: "other", At: at.Add(time.Hour)})
+		s.AddAward(Award{Team: "T1234", Receiver: "U1", Giver: "U2", Amount: 1, Reason: "other", At: at})
+		s.AddAward(Award{Team: ThingsTeam("T123"), Receiver: "coffee machine", Giver: "U2", Amount: 1, At: at})
+
+		awards, err := s.ListAwards("T123", "U1", 2)
+		if err != nil || len(awards) != 2 {
+			t.Fatalf("should return two awards, got %+v (%v)", awards, err)
+		}
+		if awards[0].Reason != "third" || awards[1].Reason != "second" {
+			t.Errorf("should return the most recent awards first, got %+v", awards)
+		}
+		if !awards[0].At.Equal(at.Add(2 * time.Minute)) {
+			t.Error("should keep the time of the award, got:", awards[0].At)
+		}
+
+		awards, err = s.ListMessageAwards("T123", "U1", "second")
+		if err != nil || len(awards) != 1 || awards[0].Reason != "second" {
+			t.Errorf("should return the awards for a message, got %+v (%v)", awards, err)
+		}
+
+		var count int
+		err = s.ForEachAward(func(a Award) error {
+			count++
+			return nil
+		})
+		if err != nil || count != 6 {
+			t.Errorf("should visit every award, got %d (%v)", count, err)
+		}
+
+		n, err := s.DeleteAwards("T123")
+		if err != nil || n != 5 {
+			t.Errorf("should delete every award for the team, got %d (%v)", n, err)
+		}
+
+		awards, _ = s.ListAwards("T1234", "U1", 10)
+		if len(awards) != 1 {
+			t.Error("should not delete awards for other teams")
+		}
+	})
+
+	t.Run("awards to things with separators", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+		team := ThingsTeam("T777")
+		for _, thing := range []string{"foo", "foo:bar", "foo%3Abar"} {
+			s.AddAward(Award{Team: team, Receiver: thing, Giver: "U2", Amount: 1, MessageTS: "1.1", At: at})
+		}
+
+		for _, thing := range []string{"foo", "foo:bar", "foo%3Abar"} {
+			awards, err := s.ListAwards(team, thing, 10)
+			if err != nil || len(awards) != 1 || awards[0].Receiver != thing {
+				t.Errorf("should only return the awards to %q, got %+v (%v)", thing, awards, err)
+			}
+			awards, err = s.ListMessageAwards(team, thing, "1.1")
+			if err != nil || len(awards) != 1 || awards[0].Receiver != thing {
+				t.Errorf("should only return the awards to %q for the message, got %+v (%v)", thing, awards, err)
+			}
+		}
+
+		if n, err := s.DeleteAwards("T777"); err != nil || n != 3 {
+			t.Errorf("should delete the awards, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("awards made at the same moment", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+		for _, ev := range []string{"Ev1", "Ev1", ""} {
+			s.AddAward(Award{Team: "T888", Receiver: "U1", Giver: "U2", Amount: 1, MessageTS: "1.1", EventID: ev, At: at})
+		}
+
+		awards, err := s.ListAwards("T888", "U1", 10)
+		if err != nil || len(awards) != 3 {
+			t.Errorf("should keep every award, got %d (%v)", len(awards), err)
+		}
+		if n, err := s.DeleteAwards("T888"); err != nil || n != 3 {
+			t.Errorf("should delete the awards, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("allowances", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+		l := Limits{PerDay: 3, PerReceiver: 2, Cooldown: time.Minute}
+
+		if _, err := s.UseAllowance("T123", "U1", "U2", at, l); err != nil {
+			t.Fatal("should allow the first award:", err)
+		}
+
+		u, err := s.UseAllowance("T123", "U1", "U2", at.Add(30*time.Second), l)
+		if err != ErrOverLimit || u.Given != 1 || !u.Last.Equal(at) {
+			t.Errorf("should enforce the cooldown, got %+v (%v)", u, err)
+		}
+
+		u, err = s.UseAllowance("T123", "U1", "U2", at.Add(time.Minute), l)
+		if err != nil || u.Given != 2 || u.GivenTo != 2 {
+			t.Errorf("should allow an award after the cooldown, got %+v (%v)", u, err)
+		}
+
+		u, err = s.UseAllowance("T123", "U1", "U2", at.Add(2*time.Minute), l)
+		if err != ErrOverLimit || u.GivenTo != 2 {
+			t.Errorf("should enforce the limit per receiver, got %+v (%v)", u, err)
+		}
+
+		u, err = s.UseAllowance("T123", "U1", "U3", at.Add(3*time.Minute), l)
+		if err != nil || u.Given != 3 || u.GivenTo != 1 {
+			t.Errorf("should allow awards to others, got %+v (%v)", u, err)
+		}
+
+		u, err = s.UseAllowance("T123", "U1", "U4", at.Add(4*time.Minute), l)
+		if err != ErrOverLimit || u.Given != 3 {
+			t.Errorf("should enforce the daily limit, got %+v (%v)", u, err)
+		}
+
+		if _, err := s.UseAllowance("T123", "U1", "U4", at.Add(24*time.Hour), l); err != nil {
+			t.Error("should reset the allowance each day:", err)
+		}
+		if _, err := s.UseAllowance("T123", "U5", "U4", at.Add(4*time.Minute), l); err != nil {
+			t.Error("should keep allowances separate per giver:", err)
+		}
+	})
+
+	t.Run("allowances over midnight", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 23, 59, 0, 0, time.UTC)
+		l := Limits{PerDay: 1, Cooldown: time.Hour}
+
+		if _, err := s.UseAllowance("T123", "U6", "U2", at, l); err != nil {
+			t.Fatal("should allow the first award:", err)
+		}
+		u, err := s.UseAllowance("T123", "U6", "U2", at.Add(2*time.Hour), l)
+		if err != ErrOverLimit || !u.Start.Equal(at) {
+			t.Errorf("should not start afresh at midnight, got %+v (%v)", u, err)
+		}
+		if _, err := s.UseAllowance("T123", "U6", "U2", at.Add(24*time.Hour), l); err != nil {
+			t.Error("should start afresh a day after the first award:", err)
+		}
+
+		if _, err := s.UseAllowance("T123", "U7", "U2", at, Limits{Cooldown: time.Hour}); err != nil {
+			t.Fatal("should allow the first award:", err)
+		}
+		if _, err := s.UseAllowance("T123", "U7", "U2", at.Add(AllowanceWindow), Limits{PerDay: 1}); err != nil {
+			t.Fatal("should allow an award in the next window:", err)
+		}
+		if _, err := s.UseAllowance("T123", "U7", "U2", at.Add(AllowanceWindow+time.Minute), Limits{Cooldown: time.Hour}); err != ErrOverLimit {
+			t.Error("should keep the cooldown when the window starts afresh, got:", err)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+
+		if err := s.ClaimEvent("Ev1:U1", at, at.Add(time.Hour)); err != nil {
+			t.Fatal("should claim a new event:", err)
+		}
+		if err := s.ClaimEvent("Ev1:U1", at.Add(time.Minute), at.Add(time.Hour)); err != ErrDuplicateEvent {
+			t.Error("should refuse a second claim on an event, got:", err)
+		}
+		if err := s.ClaimEvent("Ev1:U2", at, at.Add(time.Hour)); err != nil {
+			t.Error("should claim an event for each receiver:", err)
+		}
+		if err := s.ClaimEvent("Ev1:U1", at.Add(2*time.Hour), at.Add(3*time.Hour)); err != nil {
+			t.Error("should ignore expired claims:", err)
+		}
+
+		if err := s.ReleaseEvent("Ev1:U2"); err != nil {
+			t.Fatal("should release the claim:", err)
+		}
+		if err := s.ClaimEvent("Ev1:U2", at, at.Add(time.Hour)); err != nil {
+			t.Error("should claim a released event again:", err)
+		}
+	})
+
+	t.Run("replies", func(t *testing.T) {
+		at := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+
+		if err := s.PutReply("reply:T123:C1:1.1", "1.2", at.Add(time.Hour)); err != nil {
+			t.Fatal("should record the reply:", err)
+		}
+
+		ts, err := s.GetReply("reply:T123:C1:1.1", at)
+		if err != nil || ts != "1.2" {
+			t.Errorf("should return the reply to the message, got %s (%v)", ts, err)
+		}
+		if _, err := s.GetReply("reply:T123:C1:1.1", at.Add(time.Hour)); err != ErrNotFound {
+			t.Error("should ignore expired replies, got:", err)
+		}
+		if _, err := s.GetReply("reply:T123:C1:2.2", at); err != ErrNotFound {
+			t.Error("should return ErrNotFound for other messages, got:", err)
+		}
+
+		if err := s.DeleteReply("reply:T123:C1:1.1"); err != nil {
+			t.Fatal("should delete the reply:", err)
+		}
+		if _, err := s.GetReply("reply:T123:C1:1.1", at); err != ErrNotFound {
+			t.Error("should forget deleted replies, got:", err)
+		}
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		settings, err := s.GetSettings("T123")
+		if err != nil || !reflect.DeepEqual(settings, Settings{}) {
+			t.Errorf("should start with the defaults, got %+v (%v)", settings, err)
+		}
+
+		want := Settings{DisableMinusMinus: true}
+		if err := s.PutSettings("T123", want); err != nil {
+			t.Fatal("should store the settings:", err)
+		}
+
+		settings, err = s.GetSettings("T123")
+		if err != nil || !reflect.DeepEqual(settings, want) {
+			t.Errorf("should return the stored settings, got %+v (%v)", settings, err)
+		}
+
+		if err := s.DeleteSettings("T123"); err != nil {
+			t.Fatal("should delete the settings:", err)
+		}
+
+		settings, _ = s.GetSettings("T123")
+		if !reflect.DeepEqual(settings, Settings{}) {
+			t.Error("should return the defaults once deleted")
+		}
+	})
+
+	t.Run("delete auth record", func(t *testing.T) {
+		if err := s.DeleteAuth("T123"); err != nil {
+			t.Fatal("should delete the record:", err)
+		}
+
+		if _, err := s.GetAuth("T123"); err != ErrNotFound {
+			t.Error("should return ErrNotFound once deleted, got:", err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxThingLength is the longest name, in characters, we keep for a thing.
+const maxThingLength = 64
+
+// userIDRE matches the ID of a Slack user.
+var userIDRE = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// channelRefRE matches a channel as Slack escapes it in message text, e.g.
+// <#C123|release-channel>.
+var channelRefRE = regexp.MustCompile(`^<#([CG][A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// ThingsTeam returns the team under which scores for things, rather than
+// users, are held. Things have their own boards for each period, just as
+// users do.
+func ThingsTeam(team string) string {
+	return team + thingsSuffix
+}
+
+// thingsSuffix marks the teams returned by ThingsTeam.
+const thingsSuffix = "@things"
+
+// IsThingsTeam reports whether team holds the scores of things.
+func IsThingsTeam(team string) bool {
+	return strings.HasSuffix(team, thingsSuffix)
+}
+
+// NormaliseThing returns the name under which a thing's score is kept, so that
+// "Kubernetes", kubernetes and kubernetes! all count towards the same score.
+// Names are lower case, without surrounding quotes, trailing punctuation or
+// repeated spaces. Channels are kept by ID so they survive being renamed. It
+// returns an empty string if there is nothing left to score.
+func NormaliseThing(name string) string {
+	name = strings.TrimSpace(name)
+	if m := channelRefRE.FindStringSubmatch(name); m != nil {
+		return "<#" + m[1] + ">"
+	}
+
+	name = strings.Trim(name, "\"'“”‘’")
+	name = strings.TrimRight(name, ".,;:!?")
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+
+	if r := []rune(name); len(r) > maxThingLength {
+		name = strings.TrimSpace(string(r[:maxThingLength]))
+	}
+	return name
+}
+
+// IsThing reports whether a subject given points is a thing rather than a
+// user.
+func IsThing(subject string) bool {
+	return !userIDRE.MatchString(subject)
+}
+
+// Mention formats a subject given points for use in a message. Users and
+// channels are mentioned so that Slack shows their current names.
+func Mention(subject string) string {
+	switch {
+	case !IsThing(subject):
+		return "<@" + subject + ">"
+	case strings.HasPrefix(subject, "<#"):
+		return subject
+	default:
+		return "*" + subject + "*"
+	}
+}
@@ -0,0 +1,46 @@
+package bot
+
+import "testing"
+
+func TestNormaliseThing(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "kubernetes", want: "kubernetes"},
+		{name: "Kubernetes!", want: "kubernetes"},
+		{name: `"Coffee  Machine"`, want: "coffee machine"},
+		{name: "“coffee machine”", want: "coffee machine"},
+		{name: "node.js", want: "node.js"},
+		{name: "<#C123ABC|release-channel>", want: "<#C123ABC>"},
+		{name: "#release-channel", want: "#release-channel"},
+		{name: `"..."`, want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := NormaliseThing(tc.name); got != tc.want {
+			t.Errorf("should normalise %q to %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestMention(t *testing.T) {
+	testCases := []struct {
+		subject string
+		want    string
+	}{
+		{subject: "U123ABC", want: "<@U123ABC>"},
+		{subject: "<#C123ABC>", want: "<#C123ABC>"},
+		{subject: "kubernetes", want: "*kubernetes*"},
+	}
+
+	for _, tc := range testCases {
+		if got := Mention(tc.subject); got != tc.want {
+			t.Errorf("should mention %s as %s, got %s", tc.subject, tc.want, got)
+		}
+	}
+
+	if !isPeriodBoard("T123@things@2018-W27") || isPeriodBoard(ThingsTeam("T123")) || isPeriodBoard("T123") {
+		t.Error("should only treat boards for a period as period boards")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// collusion looks for users trading points back and forth and reports them to
+// each workspace's admin channel. It is intended to be run periodically, e.g.
+// weekly from cron.
+func collusion(args []string) error {
+	fs := flag.NewFlagSet("collusion", flag.ExitOnError)
+	window := fs.Duration("window", bot.DefaultCollusionWindow, "how far back to look for awards")
+	threshold := fs.Int("threshold", bot.DefaultCollusionThreshold, "the number of awards each way that are reported")
+	dryRun := fs.Bool("dry-run", false, "print reports instead of posting them")
+	fs.Parse(args)
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	until := time.Now()
+	n, err := b.ReportCollusion(until.Add(-*window), until, *threshold, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("INFO: reported on %d teams\n", n)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Command buddybot runs BuddyBot outside of AWS Lambda.
+//
+// Usage:
+//
+//	buddybot <command> [flags]
+//
+// Run 'buddybot' without arguments for a list of commands.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a buddybot sub-command. Run receives the arguments that follow
+// the command name and returns an error if the command fails.
+type command struct {
+	Name    string
+	Summary string
+	Run     func(args []string) error
+}
+
+var commands = []command{
+	{Name: "serve", Summary: "host the command, event, action and auth handlers over HTTP", Run: serve},
+	{Name: "purge", Summary: "delete data for workspaces that uninstalled BuddyBot", Run: purge},
+	{Name: "collusion", Summary: "report users trading points to each workspace's admins", Run: collusion},
+	{Name: "rebuild", Summary: "recalculate every score from the points ledger", Run: rebuild},
+	{Name: "settings", Summary: "show or change the settings for a workspace", Run: settings},
+	{Name: "reencrypt", Summary: "encrypt stored workspace tokens under the current master key", Run: reencrypt},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.Name != os.Args[1] {
+			continue
+		}
+
+		err := c.Run(os.Args[2:])
+		if err != nil {
+			fmt.Println("ERROR:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "buddybot: unknown command '%s'\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: buddybot <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.Name, c.Summary)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// purge deletes the install records and scores of workspaces that uninstalled
+// BuddyBot more than the configured grace period ago. It is intended to be run
+// periodically, e.g. from cron.
+func purge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	fs.Parse(args)
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	n, err := b.PurgeUninstalled()
+	if err != nil {
+		return errors.Wrapf(err, "purged %d workspaces before failing", n)
+	}
+
+	fmt.Printf("INFO: purged %d workspaces\n", n)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// rebuild recalculates every score from the points ledger. It is run after a
+// failed score update, or with -seed once to move existing scores onto the
+// ledger.
+func rebuild(args []string) error {
+	fs := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	seed := fs.Bool("seed", false, "record scores missing from the ledger as opening balances instead of discarding them")
+	dryRun := fs.Bool("dry-run", false, "report differences without changing anything")
+	fs.Parse(args)
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	r, err := b.RebuildScores(*seed, *dryRun)
+	if err != nil {
+		return errors.Wrapf(err, "checked %d scores before failing", r.Scores)
+	}
+
+	verb := "corrected"
+	if *dryRun {
+		verb = "would correct"
+	}
+	fmt.Printf("INFO: checked %d scores, %s %d and seeded %d opening balances\n", r.Scores, verb, r.Corrected, r.Seeded)
+	return nil
+}
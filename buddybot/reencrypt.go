@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// reencrypt encrypts every install record under the current master key. It is
+// run after enabling token encryption or after rotating the master key.
+func reencrypt(args []string) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	fs.Parse(args)
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	s, ok := b.Store.(*bot.EncryptedStore)
+	if !ok {
+		return errors.New("token encryption is not configured, set 'keyProvider' to enable it")
+	}
+
+	n, err := s.Reencrypt()
+	if err != nil {
+		return errors.Wrapf(err, "re-encrypted %d records before failing", n)
+	}
+
+	fmt.Printf("INFO: re-encrypted %d records with key '%s'\n", n, s.Keys.KeyID())
+	return nil
+}
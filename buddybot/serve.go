@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/billglover/buddybot/action"
+	"github.com/billglover/buddybot/auth"
+	"github.com/billglover/buddybot/bot"
+	"github.com/billglover/buddybot/cmd"
+	"github.com/billglover/buddybot/event"
+	"github.com/pkg/errors"
+)
+
+// serve hosts all four BuddyBot handlers on a single HTTP server. It blocks
+// until the server fails or the process receives SIGINT or SIGTERM, at which
+// point in-flight requests are given time to complete.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	certFile := fs.String("tls-cert", "", "TLS certificate file (enables HTTPS)")
+	keyFile := fs.String("tls-key", "", "TLS private key file")
+	grace := fs.Duration("shutdown-timeout", 10*time.Second, "time allowed for in-flight requests on shutdown")
+	fs.Parse(args)
+
+	if (*certFile == "") != (*keyFile == "") {
+		return errors.New("both -tls-cert and -tls-key must be specified to enable TLS")
+	}
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      newMux(b),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		fmt.Println("INFO: listening on", *addr)
+		if *certFile != "" {
+			errc <- srv.ListenAndServeTLS(*certFile, *keyFile)
+			return
+		}
+		errc <- srv.ListenAndServe()
+	}()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errc:
+		return errors.Wrap(err, "server failed")
+	case sig := <-sigc:
+		fmt.Println("INFO: received", sig, "shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+
+	return srv.Shutdown(ctx)
+}
+
+// newMux routes each Slack endpoint to its handler. Routes mirror the paths
+// used by the API Gateway deployment in sam.yaml.
+func newMux(b *bot.SlackBot) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/command", allow(http.MethodPost, bot.HTTPHandler(cmd.Handler(b))))
+	mux.Handle("/event", allow(http.MethodPost, bot.HTTPHandler(event.Handler(b))))
+	mux.Handle("/action", allow(http.MethodPost, bot.HTTPHandler(action.Handler(b))))
+	mux.Handle("/auth", allow(http.MethodGet, bot.HTTPHandler(auth.Handler(b))))
+	mux.Handle("/install", allow(http.MethodGet, bot.HTTPHandler(auth.Handler(b))))
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz(b))
+	return mux
+}
+
+// allow rejects requests that don't use the given method.
+func allow(method string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// healthz reports that the process is running.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether the bot is able to reach its store.
+func readyz(b *bot.SlackBot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, err := b.Store.GetScore("buddybot", "readyz")
+		if err != nil {
+			fmt.Println("WARN: readiness check failed:", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "unavailable")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	}
+}
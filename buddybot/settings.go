@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+// settings shows the settings for a workspace, changing any given as
+// name=value arguments first.
+func settings(args []string) error {
+	fs := flag.NewFlagSet("settings", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: buddybot settings <team> [name=value ...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "settings:", strings.Join(bot.SettingNames(), ", "))
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	team := fs.Arg(0)
+
+	b, err := bot.New()
+	if err != nil {
+		return errors.Wrap(err, "unable to initiate the bot")
+	}
+
+	s, err := b.Store.GetSettings(team)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get settings for '%s'", team)
+	}
+
+	if fs.NArg() > 1 {
+		for _, arg := range fs.Args()[1:] {
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) != 2 {
+				return errors.Errorf("expected name=value, got '%s'", arg)
+			}
+			if err := s.Set(kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+
+		err = b.Store.PutSettings(team, s)
+		if err != nil {
+			return errors.Wrapf(err, "unable to store settings for '%s'", team)
+		}
+		fmt.Println("INFO: updated settings for", team)
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
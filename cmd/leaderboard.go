@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/billglover/buddybot/bot"
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultLeaders is the number of users /leaderboard shows by default.
+	defaultLeaders = 10
+
+	// maxLeaders is the most users /leaderboard will show.
+	maxLeaders = 25
+)
+
+// message is a slash command response. The version of the slack package we
+// use doesn't support Block Kit, so blocks are described here.
+type message struct {
+	ResponseType string  `json:"response_type"`
+	Text         string  `json:"text"`
+	Blocks       []block `json:"blocks,omitempty"`
+}
+
+// block is a Block Kit layout block.
+type block struct {
+	Type     string  `json:"type"`
+	Text     *text   `json:"text,omitempty"`
+	Elements []*text `json:"elements,omitempty"`
+}
+
+// text is a Block Kit text object.
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// markdown returns a Block Kit text object formatted with mrkdwn.
+func markdown(s string) *text {
+	return &text{Type: "mrkdwn", Text: s}
+}
+
+// leaderboard handles /leaderboard [things] [n] [week|month|all]. The
+// leaderboard is returned as the response to the command, visible only to the
+// caller.
+func leaderboard(b *bot.SlackBot, s slack.SlashCommand) (events.APIGatewayProxyResponse, error) {
+	n, period, things, err := parseLeaderboardArgs(s.Text)
+	if err != nil {
+		return respond(message{ResponseType: "ephemeral", Text: err.Error()})
+	}
+
+	team := b.ScoreTeam(s.EnterpriseID, s.TeamID)
+	if things {
+		team = bot.ThingsTeam(team)
+	}
+
+	lb, err := b.Leaderboard(team, period, n, s.UserID)
+	if err != nil {
+		fmt.Println("WARN: unable to get leaderboard:", err)
+		resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+		return resp, nil
+	}
+
+	return respond(leaderboardMessage(lb, things))
+}
+
+// parseLeaderboardArgs reads the optional size, period and choice of the
+// things leaderboard given to /leaderboard, in any order.
+func parseLeaderboardArgs(args string) (int, bot.Period, bool, error) {
+	n, period, things := defaultLeaders, bot.AllTime, false
+
+	for _, arg := range strings.Fields(args) {
+		if strings.EqualFold(arg, "things") {
+			things = true
+			continue
+		}
+
+		if v, err := strconv.Atoi(arg); err == nil {
+			if v < 1 || v > maxLeaders {
+				return n, period, things, errors.Errorf("I can show between 1 and %d places, not %d.", maxLeaders, v)
+			}
+			n = v
+			continue
+		}
+
+		p, err := bot.ParsePeriod(arg)
+		if err != nil {
+			return n, period, things, errors.Errorf("I don't understand '%s'. Try `/leaderboard [things] [n] [week|month|all]`.", arg)
+		}
+		period = p
+	}
+
+	return n, period, things, nil
+}
+
+// leaderboardMessage lays out a leaderboard with a line per user, or thing,
+// and the caller's own standing beneath.
+func leaderboardMessage(lb bot.Leaderboard, things bool) message {
+	title := "Leaderboard " + periodName(lb.Period)
+	if things {
+		title = "Things leaderboard " + periodName(lb.Period)
+	}
+
+	var lines []string
+	for _, s := range lb.Top {
+		lines = append(lines, fmt.Sprintf("%s %s %s", rankLabel(s.Rank), bot.Mention(s.User), points(s.Score)))
+	}
+
+	body := strings.Join(lines, "\n")
+	switch {
+	case len(lines) > 0:
+	case things:
+		body = "Nothing has any points " + periodName(lb.Period) + " yet. Give something a point with `something++` :tada:"
+	default:
+		body = "Nobody has any points " + periodName(lb.Period) + " yet. Be the first to thank someone with `@someone++` :tada:"
+	}
+
+	standing := fmt.Sprintf("You're ranked %s with %s.", ordinal(lb.Caller.Rank), points(lb.Caller.Score))
+	switch {
+	case things:
+		standing = "Give points to anything with `kubernetes++` or `\"coffee machine\"++`."
+	case lb.Caller.Score == 0:
+		standing = "You don't have any points " + periodName(lb.Period) + " yet."
+	}
+
+	return message{
+		ResponseType: "ephemeral",
+		Text:         title,
+		Blocks: []block{
+			{Type: "section", Text: markdown("*" + title + "*")},
+			{Type: "section", Text: markdown(body)},
+			{Type: "divider"},
+			{Type: "context", Elements: []*text{markdown(standing)}},
+		},
+	}
+}
+
+// periodName describes a period for use in a sentence.
+func periodName(p bot.Period) string {
+	switch p {
+	case bot.Week:
+		return "this week"
+	case bot.Month:
+		return "this month"
+	default:
+		return "of all time"
+	}
+}
+
+// rankLabel shows medals for the top three ranks and numbers for the rest.
+func rankLabel(rank int) string {
+	switch rank {
+	case 1:
+		return ":first_place_medal:"
+	case 2:
+		return ":second_place_medal:"
+	case 3:
+		return ":third_place_medal:"
+	default:
+		return fmt.Sprintf("*%d.*", rank)
+	}
+}
+
+// points describes a score.
+func points(score int) string {
+	if score == 1 || score == -1 {
+		return fmt.Sprintf("%d point", score)
+	}
+	return fmt.Sprintf("%d points", score)
+}
+
+// ordinal returns n as an ordinal number, e.g. 1st, 2nd or 11th.
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// respond returns msg as the immediate response to a slash command.
+func respond(msg message) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Println("ERROR: unable to marshal response:", err)
+		resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+		return resp, nil
+	}
+
+	resp := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+	return resp, nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/billglover/buddybot/bot"
+)
+
+func TestParseLeaderboardArgs(t *testing.T) {
+	testCases := []struct {
+		args   string
+		n      int
+		period bot.Period
+		things bool
+		err    bool
+	}{
+		{args: "", n: defaultLeaders, period: bot.AllTime},
+		{args: "things", n: defaultLeaders, period: bot.AllTime, things: true},
+		{args: "5 Things week", n: 5, period: bot.Week, things: true},
+		{args: "5", n: 5, period: bot.AllTime},
+		{args: "week", n: defaultLeaders, period: bot.Week},
+		{args: "3 month", n: 3, period: bot.Month},
+		{args: "Month 3", n: 3, period: bot.Month},
+		{args: "0", err: true},
+		{args: "100", err: true},
+		{args: "year", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.args, func(t *testing.T) {
+			n, period, things, err := parseLeaderboardArgs(tc.args)
+			if tc.err {
+				if err == nil {
+					t.Error("should return an error")
+				}
+				return
+			}
+			if err != nil || n != tc.n || period != tc.period || things != tc.things {
+				t.Errorf("should return %d %s %t, got %d %s %t (%v)", tc.n, tc.period, tc.things, n, period, things, err)
+			}
+		})
+	}
+}
+
+func TestLeaderboardMessage(t *testing.T) {
+	lb := bot.Leaderboard{
+		Period: bot.Week,
+		Top: []bot.Standing{
+			{User: "U1", Score: 5, Rank: 1},
+			{User: "U2", Score: 5, Rank: 1},
+			{User: "U3", Score: 1, Rank: 3},
+			{User: "U4", Score: 0, Rank: 4},
+		},
+		Caller: bot.Standing{User: "U3", Score: 1, Rank: 3},
+	}
+
+	msg := leaderboardMessage(lb, false)
+	if msg.ResponseType != "ephemeral" || len(msg.Blocks) != 4 {
+		t.Fatalf("should return an ephemeral Block Kit message, got %+v", msg)
+	}
+
+	body := msg.Blocks[1].Text.Text
+	for _, want := range []string{":first_place_medal: <@U1> 5 points", ":first_place_medal: <@U2>", ":third_place_medal: <@U3> 1 point", "*4.* <@U4> 0 points"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("should contain %q, got %q", want, body)
+		}
+	}
+
+	if got := msg.Blocks[3].Elements[0].Text; got != "You're ranked 3rd with 1 point." {
+		t.Error("should show the caller's standing, got:", got)
+	}
+
+	msg = leaderboardMessage(bot.Leaderboard{Period: bot.Month, Caller: bot.Standing{User: "U1", Rank: 1}}, false)
+	if !strings.Contains(msg.Blocks[1].Text.Text, "Nobody has any points this month") {
+		t.Error("should explain an empty leaderboard, got:", msg.Blocks[1].Text.Text)
+	}
+
+	things := bot.Leaderboard{Period: bot.AllTime, Top: []bot.Standing{{User: "coffee machine", Score: 3, Rank: 1}, {User: "<#C123>", Score: 1, Rank: 2}}}
+	msg = leaderboardMessage(things, true)
+	if msg.Text != "Things leaderboard of all time" || !strings.Contains(msg.Blocks[1].Text.Text, ":first_place_medal: *coffee machine* 3 points\n:second_place_medal: <#C123> 1 point") {
+		t.Errorf("should list things, got %+v", msg.Blocks)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd", 111: "111th"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("should return %s, got %s", want, got)
+		}
+	}
+}
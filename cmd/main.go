@@ -1,27 +1,17 @@
-package main
+package cmd
 
 import (
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/billglover/buddybot/bot"
 	"github.com/nlopes/slack"
 )
 
-func main() {
-	b, err := bot.New()
-	if err != nil {
-		fmt.Println("ERROR: unable to initiate the bot:", err)
-		os.Exit(1)
-	}
-
-	lambda.Start(handler(b))
-}
-
-func handler(b *bot.SlackBot) bot.APIHandler {
+// Handler returns an APIHandler that handles Slack slash commands.
+func Handler(b *bot.SlackBot) bot.APIHandler {
 
 	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
@@ -42,7 +32,7 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 			fmt.Println("INFO: sent by:", s.TeamID, s.UserID, "(", s.UserName, ")")
 
 			// retrieve the appropriate bot token
-			token, _, _, err := b.RetrieveTokens(s.TeamID)
+			token, _, _, err := b.RetrieveTokensFor(s.EnterpriseID, s.TeamID)
 			if err != nil {
 				fmt.Println("WARN: unable to retrieve access token:", err)
 				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
@@ -60,6 +50,45 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 				return resp, nil
 			}
 
+		case "/reasons":
+			fmt.Println("INFO: command received:", s.Command)
+			fmt.Println("INFO: sent by:", s.TeamID, s.UserID, "(", s.UserName, ")")
+
+			token, _, _, err := b.RetrieveTokensFor(s.EnterpriseID, s.TeamID)
+			if err != nil {
+				fmt.Println("WARN: unable to retrieve access token:", err)
+				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+				return resp, nil
+			}
+
+			awards, err := b.Store.ListAwards(b.ScoreTeam(s.EnterpriseID, s.TeamID), s.UserID, maxReasons)
+			if err != nil {
+				fmt.Println("WARN: unable to list awards:", err)
+				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+				return resp, nil
+			}
+
+			api := slack.New(token)
+			_, err = api.PostEphemeral(s.ChannelID, s.UserID,
+				slack.MsgOptionPostEphemeral2(s.UserID),
+				slack.MsgOptionText(reasonsReply(awards), false),
+			)
+			if err != nil {
+				fmt.Println("WARN: failed to respond to reasons:", err)
+				resp := events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest}
+				return resp, nil
+			}
+
+		case "/leaderboard":
+			fmt.Println("INFO: command received:", s.Command, s.Text)
+			fmt.Println("INFO: sent by:", s.TeamID, s.UserID, "(", s.UserName, ")")
+			return leaderboard(b, s)
+
+		case "/score":
+			fmt.Println("INFO: command received:", s.Command, s.Text)
+			fmt.Println("INFO: sent by:", s.TeamID, s.UserID, "(", s.UserName, ")")
+			return score(b, s)
+
 		default:
 			fmt.Println("INFO: unknown command sent:", s.Command)
 		}
@@ -68,3 +97,25 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 		return resp, nil
 	}
 }
+
+// maxReasons is the number of recent awards /reasons looks at.
+const maxReasons = 10
+
+// reasonsReply lists the reasons a user has been given for their most recent
+// awards.
+func reasonsReply(awards []bot.Award) string {
+	var lines []string
+	for _, a := range awards {
+		// skip awards without a reason, and adjustments made by BuddyBot
+		if a.Reason == "" || a.Giver == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("• %+d from <@%s> <!date^%d^{date_short}|%s>\n> %s",
+			a.Amount, a.Giver, a.At.Unix(), a.At.Format("2 Jan 2006"), bot.QuietMentions(a.Reason)))
+	}
+
+	if len(lines) == 0 {
+		return "Nobody has given a reason for your recent points yet. Try `@someone++ for ...` when you next thank someone :wink:"
+	}
+	return "Here's why you've been given points recently:\n" + strings.Join(lines, "\n")
+}
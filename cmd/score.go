@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/billglover/buddybot/bot"
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+)
+
+// recentAwards is the number of recent awards /score shows.
+const recentAwards = 3
+
+// mentionRE matches a user mention as Slack escapes it in slash command text,
+// e.g. <@U123|dave>.
+var mentionRE = regexp.MustCompile(`^<@([UW][A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// score handles /score, /score @user and /score thing. The summary is returned
+// as the response to the command, visible only to the caller.
+func score(b *bot.SlackBot, s slack.SlashCommand) (events.APIGatewayProxyResponse, error) {
+	user, err := parseScoreTarget(s.Text, s.UserID)
+	if err != nil {
+		return respond(message{ResponseType: "ephemeral", Text: err.Error()})
+	}
+
+	team := b.ScoreTeam(s.EnterpriseID, s.TeamID)
+	if bot.IsThing(user) {
+		team = bot.ThingsTeam(team)
+	}
+
+	// check users other than the caller exist before reporting a score of zero
+	if user != s.UserID && !bot.IsThing(user) {
+		token, _, _, err := b.RetrieveTokensFor(s.EnterpriseID, s.TeamID)
+		if err != nil {
+			fmt.Println("WARN: unable to retrieve access token:", err)
+			resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+			return resp, nil
+		}
+
+		u, err := slack.New(token).GetUserInfo(user)
+		if err != nil {
+			fmt.Println("INFO: unable to find user", user, ":", err)
+			return respond(message{ResponseType: "ephemeral", Text: "I couldn't find that user in this workspace. Try `/score @someone`."})
+		}
+		if u.IsBot {
+			return respond(message{ResponseType: "ephemeral", Text: fmt.Sprintf("<@%s> is a bot, and bots don't get points :robot_face:", user)})
+		}
+	}
+
+	us, err := b.UserSummary(team, user, recentAwards)
+	if err != nil {
+		fmt.Println("WARN: unable to get score summary:", err)
+		resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+		return resp, nil
+	}
+
+	return respond(scoreMessage(us))
+}
+
+// parseScoreTarget returns the user or thing /score was asked about. With no
+// arguments that is the caller. Anything other than a user mention is a thing.
+func parseScoreTarget(args, caller string) (string, error) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return caller, nil
+	}
+
+	if m := mentionRE.FindStringSubmatch(args); m != nil {
+		return m[1], nil
+	}
+
+	if strings.HasPrefix(args, "@") || strings.Contains(args, "<@") {
+		return "", errors.Errorf("I don't know who '%s' is. Mention them, e.g. `/score @someone`, or use `/score` on its own for your own score.", args)
+	}
+
+	thing := bot.NormaliseThing(args)
+	if thing == "" {
+		return "", errors.Errorf("I can't score '%s'. Try `/score @someone` or `/score something`.", args)
+	}
+	return thing, nil
+}
+
+// scoreMessage lays out a user's, or thing's, score, rank, points this week
+// and the reasons for their most recent awards.
+func scoreMessage(us bot.UserSummary) message {
+	title := "Score for " + bot.Mention(us.User)
+
+	summary := fmt.Sprintf("*Total:* %s, ranked %s\n*This week:* %s", points(us.Score), ordinal(us.Rank), points(us.Week))
+	switch {
+	case us.Score != 0 || len(us.Recent) > 0:
+	case bot.IsThing(us.User):
+		summary = "No points yet. Give it some with `something++ for ...` :tada:"
+	default:
+		summary = "No points yet. Thank them with `@someone++ for ...` when they next help out :tada:"
+	}
+
+	blocks := []block{
+		{Type: "section", Text: markdown("*" + title + "*")},
+		{Type: "section", Text: markdown(summary)},
+	}
+
+	if len(us.Recent) > 0 {
+		var lines []string
+		for _, a := range us.Recent {
+			line := fmt.Sprintf("• %+d from <@%s>", a.Amount, a.Giver)
+			if a.Reason != "" {
+				line += " " + bot.QuietMentions(a.Reason)
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks,
+			block{Type: "divider"},
+			block{Type: "context", Elements: []*text{markdown("*Recently*\n" + strings.Join(lines, "\n"))}},
+		)
+	}
+
+	return message{ResponseType: "ephemeral", Text: title, Blocks: blocks}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/billglover/buddybot/bot"
+)
+
+func TestParseScoreTarget(t *testing.T) {
+	testCases := []struct {
+		args string
+		user string
+		err  bool
+	}{
+		{args: "", user: "UCALLER"},
+		{args: "  ", user: "UCALLER"},
+		{args: "<@UBLKAG9K4|dave>", user: "UBLKAG9K4"},
+		{args: " <@UBLKAG9K4> ", user: "UBLKAG9K4"},
+		{args: "<@WBLKAG9K4|dave>", user: "WBLKAG9K4"},
+		{args: "@dave", err: true},
+		{args: "Kubernetes!", user: "kubernetes"},
+		{args: `"Coffee Machine"`, user: "coffee machine"},
+		{args: "<#C123|general>", user: "<#C123>"},
+		{args: `""`, err: true},
+		{args: "<@UBLKAG9K4|dave> <@UBLPTK0JH|sue>", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.args, func(t *testing.T) {
+			user, err := parseScoreTarget(tc.args, "UCALLER")
+			if tc.err {
+				if err == nil {
+					t.Error("should return an error, got:", user)
+				}
+				return
+			}
+			if err != nil || user != tc.user {
+				t.Errorf("should return %s, got %s (%v)", tc.user, user, err)
+			}
+		})
+	}
+}
+
+func TestScoreMessage(t *testing.T) {
+	us := bot.UserSummary{
+		User:  "U1",
+		Score: 12,
+		Rank:  2,
+		Week:  1,
+		Recent: []bot.Award{
+			{Giver: "U2", Amount: 1, Reason: "for fixing the build"},
+			{Giver: "U3", Amount: -1},
+		},
+	}
+
+	msg := scoreMessage(us)
+	if len(msg.Blocks) != 4 {
+		t.Fatalf("should include recent awards, got %+v", msg.Blocks)
+	}
+	if got := msg.Blocks[1].Text.Text; got != "*Total:* 12 points, ranked 2nd\n*This week:* 1 point" {
+		t.Error("should summarise the score, got:", got)
+	}
+
+	recent := msg.Blocks[3].Elements[0].Text
+	for _, want := range []string{"• +1 from <@U2> for fixing the build", "• -1 from <@U3>"} {
+		if !strings.Contains(recent, want) {
+			t.Errorf("should contain %q, got %q", want, recent)
+		}
+	}
+
+	msg = scoreMessage(bot.UserSummary{User: "U1", Rank: 1})
+	if len(msg.Blocks) != 2 || !strings.HasPrefix(msg.Blocks[1].Text.Text, "No points yet") {
+		t.Errorf("should explain a user has no points, got %+v", msg.Blocks)
+	}
+
+	msg = scoreMessage(bot.UserSummary{User: "coffee machine", Score: 2, Rank: 1})
+	if msg.Text != "Score for *coffee machine*" {
+		t.Error("should show scores for things, got:", msg.Text)
+	}
+}
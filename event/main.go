@@ -1,35 +1,21 @@
-package main
+package event
 
 import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/billglover/buddybot/bot"
 	"github.com/nlopes/slack"
 	"github.com/nlopes/slack/slackevents"
-	"github.com/pkg/errors"
 )
 
-func main() {
-	b, err := bot.New()
-	if err != nil {
-		fmt.Println("ERROR: unable to initiate the bot:", err)
-		os.Exit(1)
-	}
-
-	lambda.Start(handler(b))
-}
-
-func handler(b *bot.SlackBot) bot.APIHandler {
+// Handler returns an APIHandler that handles Slack Events API callbacks.
+func Handler(b *bot.SlackBot) bot.APIHandler {
 
 	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
@@ -61,44 +47,144 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 
 		case slackevents.CallbackEvent:
 			cbe := e.Data.(*slackevents.EventsAPICallbackEvent)
+			ent := bot.EnterpriseID(req)
+
+			// Retries are processed as normal; claims on each award stop them being counted twice
+			if n, reason := bot.SlackRetry(req); n > 0 {
+				fmt.Printf("INFO: retry %d of event %s: %s\n", n, cbe.EventID, reason)
+			}
 
 			switch ev := e.InnerEvent.Data.(type) {
 
 			case *slackevents.AppMentionEvent:
-				token, _, _, err := b.RetrieveTokens(cbe.TeamID)
+				team := b.ScoreTeam(ent, cbe.TeamID)
+
+				settings, err := b.Store.GetSettings(team)
+				if err != nil {
+					fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
+				}
+
+				// Every message in a scanned channel arrives as a message event too
+				if settings.Scans(ev.Channel) {
+					break
+				}
+
+				token, _, _, err := b.RetrieveTokensFor(ent, cbe.TeamID)
 				if err != nil {
 					fmt.Println("WARN: unable to retrieve team access token:", err)
 					resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
 					return resp, nil
 				}
-				api := slack.New(token)
-
-				plusUsers := identifyPlusPlus(ev.Text)
 
-				for _, u := range plusUsers {
-					params := slack.PostMessageParameters{}
+				p := post{User: ev.User, Channel: ev.Channel, Text: ev.Text, TimeStamp: ev.TimeStamp, ThreadTS: ev.ThreadTimeStamp}
+				countVotes(b, token, team, settings, cbe.EventID, p)
 
-					// Don't let users boost their own egos
-					if u == ev.User {
-						reply := fmt.Sprintf("No <@%s>, try patting yourself on the back instead :stuck_out_tongue_closed_eyes:", u)
-						_, _, err := api.PostMessage(ev.Channel, reply, params)
-						if err != nil {
-							fmt.Println("WARN: unable to post message:", err)
-						}
+			case *slackevents.MessageEvent:
+				if before, after, ok := edited(ev); ok {
+					if len(identifyPlusPlus(before.Text)) == 0 && len(identifyPlusPlus(after.Text)) == 0 {
 						break
 					}
 
-					score, err := incrementScore(b, cbe.TeamID, u)
-					reply := fmt.Sprintf("Congrats <@%s>! Score now at %d :smile:", u, score)
+					team := b.ScoreTeam(ent, cbe.TeamID)
+
+					settings, err := b.Store.GetSettings(team)
 					if err != nil {
-						fmt.Println("WARN: unable to increment score:", err)
-						reply = fmt.Sprintf("Congrats <@%s>! I was unable to update your score, so you'll have to accept this smile instead :smile:", u)
+						fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
 					}
 
-					_, _, err = api.PostMessage(ev.Channel, reply, params)
+					token, _, _, err := b.RetrieveTokensFor(ent, cbe.TeamID)
+					if err != nil {
+						fmt.Println("WARN: unable to retrieve team access token:", err)
+						resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+						return resp, nil
+					}
+
+					correctVotes(b, token, team, settings, cbe.EventID, before, after)
+					break
+				}
+
+				if !scannable(ev) {
+					break
+				}
+
+				team := b.ScoreTeam(ent, cbe.TeamID)
+
+				settings, err := b.Store.GetSettings(team)
+				if err != nil {
+					fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
+				}
+
+				if !settings.Scans(ev.Channel) {
+					break
+				}
+
+				token, _, _, err := b.RetrieveTokensFor(ent, cbe.TeamID)
+				if err != nil {
+					fmt.Println("WARN: unable to retrieve team access token:", err)
+					resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+					return resp, nil
+				}
+
+				p := post{User: ev.User, Channel: ev.Channel, Text: ev.Text, TimeStamp: ev.TimeStamp, ThreadTS: ev.ThreadTimeStamp}
+				countVotes(b, token, team, settings, cbe.EventID, p)
+
+			case *bot.ReactionEvent:
+				if ev.Item.Type != "message" || ev.ItemUser == "" {
+					break
+				}
+
+				team := b.ScoreTeam(ent, cbe.TeamID)
+
+				settings, err := b.Store.GetSettings(team)
+				if err != nil {
+					fmt.Println("WARN: unable to retrieve settings, using defaults:", err)
+				}
+
+				// Reactions vote for the author of the message reacted to
+				p := post{User: ev.User, Channel: ev.Item.Channel, TimeStamp: ev.Item.TS}
+				v := vote{User: ev.ItemUser, Delta: settings.ReactionPoints(ev.Reaction), Reason: bot.ReactionReason(ev.Reaction)}
+
+				// take back whatever the reaction gave, even if the emoji no
+				// longer gives points
+				if ev.Type == bot.ReactionRemoved {
+					err := revokeVote(b, team, cbe.EventID, p, v)
 					if err != nil {
-						fmt.Println("WARN: unable to post message:", err)
+						fmt.Println("WARN: unable to revoke reaction:", err)
+						resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+						return resp, nil
 					}
+					break
+				}
+
+				if v.Delta == 0 {
+					break
+				}
+
+				token, _, _, err := b.RetrieveTokensFor(ent, cbe.TeamID)
+				if err != nil {
+					fmt.Println("WARN: unable to retrieve team access token:", err)
+					resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+					return resp, nil
+				}
+
+				locale := replyLocale(token, settings, p.User)
+				line, counted := castVote(b, token, team, settings, cbe.EventID, p, v, locale)
+				acknowledgeReaction(b, token, team, settings, p, line, counted)
+
+			case *slackevents.AppUninstalledEvent:
+				err := b.Uninstall(ent, cbe.TeamID, "app_uninstalled")
+				if err != nil {
+					fmt.Println("ERROR: unable to uninstall team:", err)
+					resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+					return resp, nil
+				}
+
+			case *bot.TokensRevokedEvent:
+				err := b.RevokeTokens(ent, cbe.TeamID, ev)
+				if err != nil {
+					fmt.Println("ERROR: unable to revoke tokens:", err)
+					resp := events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+					return resp, nil
 				}
 			}
 
@@ -111,47 +197,581 @@ func handler(b *bot.SlackBot) bot.APIHandler {
 	}
 }
 
-// IdentifyPlusPlus takes a message and returns a slice of users tagged for PlusPlus.
-func identifyPlusPlus(msg string) []string {
-	var users []string
-	var re = regexp.MustCompile(`(?m)\<\@(\w+)\>\+\+`)
-	for _, match := range re.FindAllStringSubmatch(msg, -1) {
-		users = append(users, string(match[1]))
+// post is a message that may contain votes. ThreadTS is the timestamp of the
+// thread the message is in, if any.
+type post struct {
+	User      string
+	Channel   string
+	Text      string
+	TimeStamp string
+	ThreadTS  string
+}
+
+// thread returns the timestamp of the thread replies to the message go in.
+func (p post) thread() string {
+	if p.ThreadTS != "" {
+		return p.ThreadTS
+	}
+	return p.TimeStamp
+}
+
+// scannable reports whether a message event is an ordinary message from a
+// user in a public or private channel. Bot messages, edits and other subtypes
+// are ignored.
+func scannable(ev *slackevents.MessageEvent) bool {
+	if ev.BotID != "" || ev.SubType != "" || ev.Edited != nil || ev.User == "" {
+		return false
+	}
+	return ev.ChannelType == "channel" || ev.ChannelType == "group"
+}
+
+// edited returns a message as it was before and after it was edited or
+// deleted. A deleted message has no text afterwards. It reports false if the
+// event isn't an edit or deletion of a user's message in a channel.
+func edited(ev *slackevents.MessageEvent) (before, after post, ok bool) {
+	prev := ev.PreviousMessage
+	if prev == nil || prev.BotID != "" || prev.User == "" {
+		return before, after, false
+	}
+	if ev.ChannelType != "channel" && ev.ChannelType != "group" {
+		return before, after, false
+	}
+
+	before = post{User: prev.User, Channel: ev.Channel, Text: prev.Text, TimeStamp: prev.TimeStamp, ThreadTS: prev.ThreadTimeStamp}
+	after = before
+	after.Text = ""
+
+	switch ev.SubType {
+	case "message_changed":
+		if ev.Message == nil {
+			return before, after, false
+		}
+		after.Text = ev.Message.Text
+	case "message_deleted":
+	default:
+		return before, after, false
+	}
+
+	return before, after, true
+}
+
+// correctVotes recounts the votes in a message after it has been edited or
+// deleted. Points recorded for the message that it no longer gives are taken
+// back, and the reply about them is corrected or removed. New votes added by
+// an edit are only counted in channels where every message is scanned.
+func correctVotes(b *bot.SlackBot, token, team string, settings bot.Settings, eventID string, before, after post) {
+	want := make(map[string]vote)
+	var subjects []string
+	for _, v := range identifyPlusPlus(after.Text) {
+		if _, ok := want[v.subject()]; ok || v.User == after.User || v.Delta < 0 && settings.DisableMinusMinus {
+			continue
+		}
+		want[v.subject()] = v
+		subjects = append(subjects, v.subject())
+	}
+	for _, v := range identifyPlusPlus(before.Text) {
+		if _, ok := want[v.subject()]; !ok {
+			want[v.subject()] = vote{User: v.User, Thing: v.Thing}
+			subjects = append(subjects, v.subject())
+		}
+	}
+
+	locale := replyLocale(token, settings, after.User)
+	changed, cast := false, false
+	var lines []string
+	for _, subject := range subjects {
+		v := want[subject]
+
+		recorded, err := b.GivenFor(awardTeam(team, v), after.User, subject, after.TimeStamp, "")
+		if err != nil {
+			fmt.Println("WARN: unable to find the points given by the message:", err)
+			continue
+		}
+
+		switch {
+		case recorded == v.Delta:
+		case recorded == 0:
+			if !settings.Scans(after.Channel) {
+				continue
+			}
+			line, counted := castVote(b, token, team, settings, eventID, after, v, locale)
+			if counted {
+				changed, cast = true, true
+				lines = append(lines, line)
+			}
+			continue
+		default:
+			claimed, err := b.ClaimEvent(eventID, subject)
+			if err != nil || !claimed {
+				continue
+			}
+
+			correction := v
+			correction.Delta = v.Delta - recorded
+			_, err = updateScore(b, team, eventID, after, correction)
+			if err != nil {
+				fmt.Println("WARN: unable to correct score:", err)
+				release(b, eventID, subject)
+				continue
+			}
+			changed = true
+		}
+
+		if v.Delta != 0 {
+			score, err := b.Store.GetScore(awardTeam(team, v), subject)
+			lines = append(lines, scoreReply(settings, v, score, err, locale))
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	ts, err := b.Reply(team, after.Channel, after.TimeStamp)
+	if err == bot.ErrNotFound {
+		// Only respond afresh if the edit added votes
+		if cast {
+			acknowledge(b, token, team, settings, after, lines, true)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Println("WARN: unable to get reply, leaving it as it is:", err)
+		return
+	}
+
+	api := slack.New(token)
+	if len(lines) == 0 {
+		_, _, err := api.DeleteMessage(after.Channel, ts)
+		if err != nil {
+			fmt.Println("WARN: unable to delete reply:", err)
+		}
+		err = b.ForgetReply(team, after.Channel, after.TimeStamp)
+		if err != nil {
+			fmt.Println("WARN: unable to forget reply:", err)
+		}
+		return
+	}
+
+	_, _, _, err = api.UpdateMessage(after.Channel, ts, strings.Join(lines, "\n"))
+	if err != nil {
+		fmt.Println("WARN: unable to update reply:", err)
+	}
+}
+
+// countVotes finds the votes in a message, casts each of them and
+// acknowledges them with a single response.
+func countVotes(b *bot.SlackBot, token, team string, settings bot.Settings, eventID string, p post) {
+	votes := identifyPlusPlus(p.Text)
+	if len(votes) == 0 {
+		return
 	}
-	return users
+
+	locale := replyLocale(token, settings, p.User)
+	var lines []string
+	counted := false
+	for _, v := range votes {
+		line, ok := castVote(b, token, team, settings, eventID, p, v, locale)
+		if line != "" {
+			lines = append(lines, line)
+		}
+		counted = counted || ok
+	}
+
+	acknowledge(b, token, team, settings, p, lines, counted)
 }
 
-// IncrementScore takes a team and a user an increments the score by one. It returns the
-// new score or an error.
-func incrementScore(b *bot.SlackBot, team, user string) (int, error) {
+// castVote records a vote given in a message, if it is allowed. It returns
+// the line to include in the response to the message, in the locale given if
+// there is one, and whether the vote was counted.
+func castVote(b *bot.SlackBot, token, team string, settings bot.Settings, eventID string, p post, v vote, locale string) (string, bool) {
+	if v.Delta < 0 && settings.DisableMinusMinus {
+		fmt.Println("INFO: ignoring -- as it is disabled for", team)
+		return "", false
+	}
 
-	score := 0
+	// Don't let users boost their own egos, or knock themselves down
+	if v.User != "" && v.User == p.User {
+		data := bot.MessageData{User: bot.Mention(v.User)}
+		if v.Delta < 0 {
+			return settings.Message("selfMinus", data, locale), false
+		}
+		return settings.Message("selfPlus", data, locale), false
+	}
 
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(b.Region)})
+	// Only count each vote once, however many times Slack sends the event
+	claimed, err := b.ClaimEvent(eventID, v.subject())
+	if err != nil {
+		fmt.Println("WARN: unable to check for a duplicate event, counting the vote:", err)
+		claimed = true
+	}
+	if !claimed {
+		fmt.Println("INFO: ignoring vote already counted for event", eventID)
+		return "", false
+	}
+
+	// Tell givers who are over their limits, without embarrassing them publicly
+	err = b.UseAllowance(team, p.User, v.subject(), settings.Limits())
+	if le, ok := err.(*bot.LimitError); ok {
+		fmt.Println("INFO: award from", p.User, "refused:", le)
+		if locale == "" {
+			locale = userLocale(token, p.User)
+		}
+		_, err := slack.New(token).PostEphemeral(p.Channel, p.User,
+			slack.MsgOptionPostEphemeral2(p.User),
+			slack.MsgOptionText(le.Text(settings, locale), false),
+		)
+		if err != nil {
+			fmt.Println("WARN: unable to post message:", err)
+		}
+		return "", false
+	}
 	if err != nil {
-		return score, errors.Wrap(err, "unable to create session")
+		fmt.Println("WARN: unable to check allowance, allowing the award:", err)
+	}
+
+	score, err := updateScore(b, team, eventID, p, v)
+	if err != nil {
+		fmt.Println("WARN: unable to update score:", err)
+		release(b, eventID, v.subject())
+	}
+	return scoreReply(settings, v, score, err, locale), err == nil
+}
+
+// acknowledge responds to the votes in a message in the style the workspace
+// has chosen, with the lines given as a single reply. A reaction is only
+// added if a vote was counted. Replies in the channel or thread are
+// remembered, when the votes came from the message's text, so that they can
+// be corrected if the message is edited.
+func acknowledge(b *bot.SlackBot, token, team string, settings bot.Settings, p post, lines []string, counted bool) {
+	text := strings.Join(lines, "\n")
+	api := slack.New(token)
+
+	switch settings.Replies() {
+	case bot.ReplyReaction:
+		if !counted {
+			return
+		}
+		err := api.AddReaction(settings.Emoji(), slack.NewRefToMessage(p.Channel, p.TimeStamp))
+		if err != nil {
+			fmt.Println("WARN: unable to add reaction:", err)
+		}
+
+	case bot.ReplyEphemeral:
+		if text == "" {
+			return
+		}
+		_, err := api.PostEphemeral(p.Channel, p.User,
+			slack.MsgOptionPostEphemeral2(p.User),
+			slack.MsgOptionText(text, false),
+		)
+		if err != nil {
+			fmt.Println("WARN: unable to post message:", err)
+		}
+
+	default:
+		if text == "" {
+			return
+		}
+		params := slack.PostMessageParameters{}
+		if settings.Replies() == bot.ReplyThread {
+			params.ThreadTimestamp = p.thread()
+		}
+		_, ts, err := api.PostMessage(p.Channel, text, params)
+		if err != nil {
+			fmt.Println("WARN: unable to post message:", err)
+			return
+		}
+
+		if p.Text != "" {
+			err := b.RecordReply(team, p.Channel, p.TimeStamp, ts)
+			if err != nil {
+				fmt.Println("WARN: unable to record reply:", err)
+			}
+		}
 	}
+}
 
-	ddb := dynamodb.New(sess)
+// acknowledgeReaction responds to points given by reacting to a message.
+// Reactions are often added by many people at once, so they are never
+// answered in the channel or thread. Only the reply emoji is added, when the
+// workspace has chosen to react to votes, and the person reacting is told
+// privately if they've chosen private replies or their vote wasn't counted.
+func acknowledgeReaction(b *bot.SlackBot, token, team string, settings bot.Settings, p post, line string, counted bool) {
+	if line == "" {
+		return
+	}
 
-	input := &dynamodb.UpdateItemInput{
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":s": {N: aws.String("1")}},
-		TableName:                 aws.String(b.ScoreTable),
-		Key:                       map[string]*dynamodb.AttributeValue{"uid": {S: aws.String(team + ":" + user)}},
-		ReturnValues:              aws.String("UPDATED_NEW"),
-		UpdateExpression:          aws.String("add score :s"),
+	switch {
+	case !counted:
+		settings.ReplyStyle = bot.ReplyEphemeral
+		acknowledge(b, token, team, settings, p, []string{line}, counted)
+	case settings.Replies() == bot.ReplyReaction, settings.Replies() == bot.ReplyEphemeral:
+		acknowledge(b, token, team, settings, p, []string{line}, counted)
 	}
+}
 
-	v, err := ddb.UpdateItem(input)
+// revokeVote takes back the points a user gave for a message by reacting to
+// it, as recorded in the ledger rather than the points the reaction gives
+// now. Nothing is posted, as the reaction has already gone.
+func revokeVote(b *bot.SlackBot, team, eventID string, p post, v vote) error {
+	claimed, err := b.ClaimEvent(eventID, v.User)
+	if err != nil || !claimed {
+		return err
+	}
+
+	given, err := b.GivenFor(team, p.User, v.User, p.TimeStamp, v.Reason)
+	if err == nil && given != 0 {
+		v.Delta = -given
+		_, err = updateScore(b, team, eventID, p, v)
+	}
 	if err != nil {
-		return score, errors.Wrap(err, "unable to update database")
+		release(b, eventID, v.User)
+	}
+	return err
+}
+
+// release gives up the claim on an event for a receiver after failing to
+// count a vote, so that Slack's retry can count it instead.
+func release(b *bot.SlackBot, eventID, receiver string) {
+	err := b.ReleaseEvent(eventID, receiver)
+	if err != nil {
+		fmt.Println("WARN: unable to release event:", err)
+	}
+}
+
+// maxReasonLength is the longest reason, in characters, we keep for a vote.
+const maxReasonLength = 280
+
+// vote is a single ++ or -- found in a message, along with the reason given
+// for it. Votes are for either a User or a Thing.
+type vote struct {
+	User   string
+	Thing  string
+	Delta  int
+	Reason string
+}
+
+// subject returns the user or thing the vote is for.
+func (v vote) subject() string {
+	if v.Thing != "" {
+		return v.Thing
+	}
+	return v.User
+}
+
+// voteRE matches a vote for a user, a channel, a quoted phrase or a single
+// word, in that order.
+var voteRE = regexp.MustCompile(`<@(\w+)>(\+\+|--)|(<#[CG]\w+(?:\|[^>]*)?>)(\+\+|--)|["“]([^"“”\n]+)["”](\+\+|--)|(#?\b\w[\w.\-]*)(\+\+|--)`)
+
+// wordGroup is the index in a voteRE match of the start of a single word.
+const wordGroup = 14
+
+// wordVote reports whether the single word between start and end of scan,
+// followed by ++ or -- up to the given end, is a vote. The word must stand
+// alone, be at least two characters long and come at the start of a line,
+// after a comma, or straight after the previous vote, which ends at prev, as
+// in "<@U1>++ kubernetes++". Words only take points away with --, as in "#flaky--", if
+// they start with a #, since -- is so often used as a dash.
+func wordVote(scan string, start, end, voteEnd, prev int) bool {
+	word := strings.TrimPrefix(scan[start:end], "#")
+	if len(word) < 2 {
+		return false
+	}
+	if scan[end:voteEnd] == "--" && !strings.HasPrefix(scan[start:end], "#") {
+		return false
+	}
+	if start > 0 && !unicode.IsSpace(rune(scan[start-1])) {
+		return false
+	}
+	if voteEnd < len(scan) && !unicode.IsSpace(rune(scan[voteEnd])) && !strings.ContainsRune(",.;:!?)", rune(scan[voteEnd])) {
+		return false
+	}
+
+	if nl := strings.LastIndexAny(scan[:start], "\r\n"); nl+1 > prev {
+		prev = nl + 1
+	}
+	between := strings.TrimRight(scan[prev:start], " \t")
+	if strings.HasSuffix(between, ",") || strings.HasSuffix(between, ";") {
+		return true
+	}
+	between = strings.TrimSpace(between)
+	return between == "" || between == "and" || between == "&amp;"
+}
+
+// ignoreRE matches code and links in a message, where ++ and -- aren't votes.
+var ignoreRE = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`|<(?:https?|mailto):[^>]*>")
+
+// IdentifyPlusPlus takes a message and returns the users and things tagged
+// for PlusPlus or MinusMinus, in the order they appear. The reason for each
+// vote is the text that follows it, up to the next vote or the end of the
+// line. Votes run together, as in "<@U1>++ <@U2>++ for pairing", share the
+// reason that follows. Things are normalised so that each has a single score.
+func identifyPlusPlus(msg string) []vote {
+	// blank out code and links, keeping offsets the same, so that i++ isn't a vote
+	scan := ignoreRE.ReplaceAllStringFunc(msg, func(c string) string {
+		return strings.Repeat(" ", len(c))
+	})
+
+	var votes []vote
+	var spans [][]int
+	for _, m := range voteRE.FindAllStringSubmatchIndex(scan, -1) {
+		// ignore dashes within words, as in "well--said", and users who
+		// haven't been mentioned properly, as in "@dave++"
+		if m[1] < len(scan) && isWordByte(scan[m[1]]) || m[0] > 0 && scan[m[0]-1] == '@' {
+			continue
+		}
+
+		// ++ and -- after a word are common in prose, as in "I love C++",
+		// so single words are held to stricter rules
+		if m[wordGroup] >= 0 {
+			prev := 0
+			if len(spans) > 0 {
+				prev = spans[len(spans)-1][1]
+			}
+			if !wordVote(scan, m[wordGroup], m[wordGroup+1], m[wordGroup+3], prev) {
+				continue
+			}
+		}
+
+		// each alternative captures a subject followed by ++ or --
+		v := vote{}
+		for g := 2; g < len(m); g += 4 {
+			if m[g] < 0 {
+				continue
+			}
+			if g == 2 {
+				v.User = msg[m[g]:m[g+1]]
+			} else {
+				v.Thing = bot.NormaliseThing(msg[m[g]:m[g+1]])
+			}
+			v.Delta = 1
+			if msg[m[g+2]:m[g+3]] == "--" {
+				v.Delta = -1
+			}
+		}
+		if v.User == "" && v.Thing == "" {
+			continue
+		}
+
+		votes = append(votes, v)
+		spans = append(spans, m[:2])
+	}
+
+	for i := range votes {
+		end := len(msg)
+		if i+1 < len(votes) {
+			end = spans[i+1][0]
+		}
+		votes[i].Reason = reason(msg[spans[i][1]:end])
+	}
+
+	for i := len(votes) - 2; i >= 0; i-- {
+		if votes[i].Reason == "" && votes[i].Delta == votes[i+1].Delta {
+			votes[i].Reason = votes[i+1].Reason
+		}
+	}
+	return votes
+}
+
+// isWordByte reports whether c can be part of a word.
+func isWordByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// reason tidies the text following a vote into the reason for it.
+func reason(text string) string {
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		text = text[:i]
 	}
+	text = strings.Trim(bot.QuietMentions(text), " \t,.;:")
 
-	err = dynamodbattribute.Unmarshal(v.Attributes["score"], &score)
+	// drop a conjunction leading into the next vote
+	for _, c := range []string{"and", "&amp;"} {
+		if text == c || strings.HasSuffix(text, " "+c) {
+			text = strings.Trim(strings.TrimSuffix(text, c), " \t,.;:")
+		}
+	}
+
+	if r := []rune(text); len(r) > maxReasonLength {
+		text = strings.TrimSpace(string(r[:maxReasonLength])) + "…"
+	}
+	return text
+}
+
+// UpdateScore takes a team and a vote and records it in the ledger, keeping a
+// note of who gave it, where and why. It returns the new score or an error.
+func updateScore(b *bot.SlackBot, team, eventID string, p post, v vote) (int, error) {
+	a := bot.Award{
+		Team:      awardTeam(team, v),
+		Receiver:  v.subject(),
+		Giver:     p.User,
+		Channel:   p.Channel,
+		MessageTS: p.TimeStamp,
+		Amount:    v.Delta,
+		Reason:    v.Reason,
+		EventID:   eventID,
+	}
+
+	return b.RecordAward(a)
+}
 
+// awardTeam returns the team a vote is recorded against. Votes for things
+// are recorded against the team's things.
+func awardTeam(team string, v vote) string {
+	if v.Thing != "" {
+		return bot.ThingsTeam(team)
+	}
+	return team
+}
+
+// scoreReply returns the message posted once a vote has been counted, or has
+// failed to be, in the locale given if there is one. The reason for the vote
+// is quoted beneath.
+func scoreReply(settings bot.Settings, v vote, score int, err error, locale string) string {
+	var name string
+	switch {
+	case v.Thing != "" && v.Delta < 0:
+		name = "thingMinus"
+	case v.Thing != "":
+		name = "thingPlus"
+	case v.Delta < 0:
+		name = "minus"
+	default:
+		name = "plus"
+	}
 	if err != nil {
-		return score, errors.Wrap(err, "unable to unmarshal return value")
+		name += "Failed"
+	}
+
+	data := bot.MessageData{Subject: bot.Mention(v.subject()), Score: score, Reason: v.Reason}
+	if v.User != "" {
+		data.User = bot.Mention(v.User)
+	}
+
+	reply := settings.Message(name, data, locale)
+	if v.Reason != "" {
+		reply += "\n" + settings.Message("reason", data, locale)
 	}
+	return reply
+}
 
-	return score, nil
+// replyLocale returns the locale of the user replies to a message are sent
+// to, if replies are only shown to them. Otherwise replies are for everyone,
+// so it returns "" and the workspace's locale is used.
+func replyLocale(token string, settings bot.Settings, user string) string {
+	if settings.Replies() != bot.ReplyEphemeral {
+		return ""
+	}
+	return userLocale(token, user)
+}
+
+// userLocale returns the locale a user has chosen in Slack, or "" if it
+// can't be found.
+func userLocale(token, user string) string {
+	locale, err := bot.UserLocale(token, user)
+	if err != nil {
+		fmt.Println("WARN: unable to find the user's locale, using the workspace's:", err)
+	}
+	return locale
 }
@@ -1,59 +1,339 @@
-package main
+package event
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/billglover/buddybot/bot"
+	"github.com/nlopes/slack/slackevents"
 )
 
 var testCases = []struct {
 	name  string
 	msg   string
-	users []string
+	votes []vote
 }{
 	{
 		name:  "no user mention or ++",
 		msg:   "This is some text",
-		users: []string{},
+		votes: []vote{},
 	},
 	{
 		name:  "no user mention with ++",
 		msg:   "This is some text++",
-		users: []string{},
+		votes: []vote{},
 	},
 	{
 		name:  "user mention without ++",
 		msg:   "This is some text <@UBLKAG9K4>",
-		users: []string{},
+		votes: []vote{},
 	},
 	{
 		name:  "single ++ mention",
 		msg:   "This is some text <@UBLKAG9K4>++",
-		users: []string{"UBLKAG9K4"},
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1}},
 	},
 	{
 		name:  "invalid user mention with ++",
 		msg:   "This is an invalid user @Dave++",
-		users: []string{},
+		votes: []vote{},
 	},
 	{
 		name:  "multiple user mentions with ++",
 		msg:   "This is a double <@UBLKAG9K4>++ and <@UBLPTK0JH>++.",
-		users: []string{"UBLKAG9K4", "UBLPTK0JH"},
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1}, {User: "UBLPTK0JH", Delta: 1}},
+	},
+	{
+		name:  "single -- mention",
+		msg:   "This is some text <@UBLKAG9K4>--",
+		votes: []vote{{User: "UBLKAG9K4", Delta: -1}},
+	},
+	{
+		name:  "invalid user mention with --",
+		msg:   "This is an invalid user @Dave--",
+		votes: []vote{},
+	},
+	{
+		name:  "mixed ++ and -- mentions",
+		msg:   "<@UBLKAG9K4>-- for breaking it and <@UBLPTK0JH>++ for fixing it",
+		votes: []vote{{User: "UBLKAG9K4", Delta: -1, Reason: "for breaking it"}, {User: "UBLPTK0JH", Delta: 1, Reason: "for fixing it"}},
+	},
+	{
+		name:  "same user with ++ and --",
+		msg:   "<@UBLKAG9K4>++ <@UBLKAG9K4>--",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1}, {User: "UBLKAG9K4", Delta: -1}},
+	},
+	{
+		name:  "++ with a reason",
+		msg:   "<@UBLKAG9K4>++ for fixing the build!",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1, Reason: "for fixing the build!"}},
+	},
+	{
+		name:  "reason ends at the line",
+		msg:   "<@UBLKAG9K4>++ for fixing the build.\nLunch is at 12",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1, Reason: "for fixing the build"}},
+	},
+	{
+		name:  "reasons can't ping everyone",
+		msg:   "<@UBLKAG9K4>++ for telling <!channel> and <!subteam^S0123|@platform> about the outage",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1, Reason: "for telling @channel and @platform about the outage"}},
+	},
+	{
+		name:  "votes run together share a reason",
+		msg:   "<@UBLKAG9K4>++, <@UBLPTK0JH>++ for pairing on the fix",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1, Reason: "for pairing on the fix"}, {User: "UBLPTK0JH", Delta: 1, Reason: "for pairing on the fix"}},
+	},
+	{
+		name:  "reasons are not shared between ++ and --",
+		msg:   "<@UBLKAG9K4>++ <@UBLPTK0JH>-- for breaking the build",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1}, {User: "UBLPTK0JH", Delta: -1, Reason: "for breaking the build"}},
+	},
+	{
+		name:  "single + or - is not a vote",
+		msg:   "<@UBLKAG9K4>+ <@UBLPTK0JH>- <@UBLKAG9K4>+-",
+		votes: []vote{},
+	},
+	{
+		name:  "things with ++ and --",
+		msg:   "Kubernetes++ for scaling, \"Coffee Machine\"-- and <#C0123ABCD|release-channel>++",
+		votes: []vote{{Thing: "kubernetes", Delta: 1, Reason: "for scaling"}, {Thing: "coffee machine", Delta: -1}, {Thing: "<#C0123ABCD>", Delta: 1}},
+	},
+	{
+		name:  "things and users together",
+		msg:   "<@UBLKAG9K4>++ node.js++ for the new API",
+		votes: []vote{{User: "UBLKAG9K4", Delta: 1, Reason: "for the new API"}, {Thing: "node.js", Delta: 1, Reason: "for the new API"}},
+	},
+	{
+		name:  "dashes within words are not votes",
+		msg:   "well--said and a---b",
+		votes: []vote{},
+	},
+	{
+		name:  "thing with ++",
+		msg:   "kubernetes++",
+		votes: []vote{{Thing: "kubernetes", Delta: 1}},
+	},
+	{
+		name:  "things on their own lines",
+		msg:   "Thanks all!\nnode.js++ for the new API\n#flaky-tests-- and docker++",
+		votes: []vote{{Thing: "node.js", Delta: 1, Reason: "for the new API"}, {Thing: "#flaky-tests", Delta: -1}, {Thing: "docker", Delta: 1}},
+	},
+	{
+		name:  "words in prose are not votes",
+		msg:   "I love C++ a lot",
+		votes: []vote{},
+	},
+	{
+		name:  "dashes after words are not votes",
+		msg:   "I know-- that",
+		votes: []vote{},
+	},
+	{
+		name:  "single letters and -- are not votes",
+		msg:   "C++ is fast\nyes-- that's right",
+		votes: []vote{},
+	},
+	{
+		name:  "code and links are not votes",
+		msg:   "try `i++` or <https://example.com/c++|c++>",
+		votes: []vote{},
 	},
 }
 
 func TestIdentifyPlusPlus(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(st *testing.T) {
-			users := identifyPlusPlus(tc.msg)
+			votes := identifyPlusPlus(tc.msg)
 
-			if len(users) != len(tc.users) {
-				t.Error("should return the correct number of users")
+			if len(votes) != len(tc.votes) {
+				t.Error("should return the correct number of votes")
 			}
 
-			if len(tc.users) > 0 && reflect.DeepEqual(users, tc.users) == false {
-				t.Error("should return the correct list of users")
+			if len(tc.votes) > 0 && reflect.DeepEqual(votes, tc.votes) == false {
+				t.Error("should return the correct list of votes")
+			}
+		})
+	}
+}
+
+func TestScoreReply(t *testing.T) {
+	replyCases := []struct {
+		name     string
+		settings bot.Settings
+		locale   string
+		vote     vote
+		score    int
+		err      error
+		want     string
+	}{
+		{name: "plus", vote: vote{User: "U1", Delta: 1}, score: 3, want: "Congrats <@U1>! Score now at 3"},
+		{name: "plus failed", vote: vote{User: "U1", Delta: 1}, err: errors.New("oops"), want: "unable to update your score"},
+		{name: "minus", vote: vote{User: "U1", Delta: -1}, score: -2, want: "Ouch <@U1>! Score now down to -2"},
+		{name: "minus failed", vote: vote{User: "U1", Delta: -1}, err: errors.New("oops"), want: "unable to update your score"},
+		{name: "thing plus", vote: vote{Thing: "kubernetes", Delta: 1}, score: 3, want: "*kubernetes*++ :tada: Score now at 3"},
+		{name: "thing minus", vote: vote{Thing: "coffee machine", Delta: -1}, score: -1, want: "*coffee machine*-- :grimacing: Score now down to -1"},
+		{name: "thing failed", vote: vote{Thing: "kubernetes", Delta: 1}, err: errors.New("oops"), want: "unable to update its score"},
+		{name: "with reason", vote: vote{User: "U1", Delta: 1, Reason: "for fixing the build"}, score: 3, want: "Score now at 3 :smile:\n> for fixing the build"},
+		{name: "workspace locale", settings: bot.Settings{Locale: "fr"}, vote: vote{User: "U1", Delta: 1}, score: 3, want: "Bravo <@U1> ! Score maintenant à 3"},
+		{name: "user locale", locale: "fr-FR", vote: vote{Thing: "kubernetes", Delta: -1}, score: -1, want: "*kubernetes*-- :grimacing: Score maintenant descendu à -1"},
+		{name: "workspace message", settings: bot.Settings{Messages: map[string]string{"plus": "Nice one {{.User}}, that's {{.Score}}"}}, vote: vote{User: "U1", Delta: 1}, score: 3, want: "Nice one <@U1>, that's 3"},
+	}
+
+	for _, tc := range replyCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scoreReply(tc.settings, tc.vote, tc.score, tc.err, tc.locale)
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("should contain %q, got %q", tc.want, got)
 			}
 		})
 	}
 }
+
+func TestScannable(t *testing.T) {
+	testCases := []struct {
+		name string
+		ev   slackevents.MessageEvent
+		want bool
+	}{
+		{name: "channel message", ev: slackevents.MessageEvent{User: "U1", ChannelType: "channel"}, want: true},
+		{name: "private channel message", ev: slackevents.MessageEvent{User: "U1", ChannelType: "group"}, want: true},
+		{name: "direct message", ev: slackevents.MessageEvent{User: "U1", ChannelType: "im"}},
+		{name: "bot message", ev: slackevents.MessageEvent{BotID: "B1", ChannelType: "channel"}},
+		{name: "edit", ev: slackevents.MessageEvent{SubType: "message_changed", ChannelType: "channel"}},
+		{name: "edited", ev: slackevents.MessageEvent{User: "U1", ChannelType: "channel", Edited: &slackevents.Edited{}}},
+		{name: "channel join", ev: slackevents.MessageEvent{User: "U1", SubType: "channel_join", ChannelType: "channel"}},
+	}
+
+	for _, tc := range testCases {
+		if got := scannable(&tc.ev); got != tc.want {
+			t.Errorf("%s: should return %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestRevokeVote(t *testing.T) {
+	s := bot.NewMemoryStore()
+	b := &bot.SlackBot{Store: s}
+	p := post{User: "U2", Channel: "C1", TimeStamp: "1.1"}
+	v := vote{User: "U1", Delta: 2, Reason: ":taco:"}
+
+	if err := revokeVote(b, "T123", "Ev1", p, v); err != nil {
+		t.Fatal("should ignore reactions that gave no points:", err)
+	}
+	if score, _ := s.GetScore("T123", "U1"); score != 0 {
+		t.Error("should not take away points that weren't given, got:", score)
+	}
+
+	updateScore(b, "T123", "Ev2", p, v)
+	if err := revokeVote(b, "T123", "Ev3", p, v); err != nil {
+		t.Fatal("should revoke the reaction:", err)
+	}
+	if score, _ := s.GetScore("T123", "U1"); score != 0 {
+		t.Error("should take back the points given, got:", score)
+	}
+
+	if claimed, _ := b.ClaimEvent("Ev3", "U1"); claimed {
+		t.Error("should claim the event so that retries are ignored")
+	}
+	// the emoji was removed from the workspace's reactions after it was used
+	updateScore(b, "T123", "Ev4", p, v)
+	if err := revokeVote(b, "T123", "Ev5", p, vote{User: "U1", Reason: ":taco:"}); err != nil {
+		t.Fatal("should revoke the reaction:", err)
+	}
+	if score, _ := s.GetScore("T123", "U1"); score != 0 {
+		t.Error("should take back the points given whatever the emoji gives now, got:", score)
+	}
+}
+
+func TestEdited(t *testing.T) {
+	prev := &slackevents.MessageEvent{User: "U1", Text: "<@U2>++", TimeStamp: "1.1"}
+
+	ev := &slackevents.MessageEvent{SubType: "message_changed", Channel: "C1", ChannelType: "channel", PreviousMessage: prev, Message: &slackevents.MessageEvent{User: "U1", Text: "thanks"}}
+	before, after, ok := edited(ev)
+	if !ok || before.Text != "<@U2>++" || after.Text != "thanks" || after.User != "U1" || after.TimeStamp != "1.1" || after.Channel != "C1" {
+		t.Errorf("should return the message before and after the edit, got %+v %+v %t", before, after, ok)
+	}
+
+	ev = &slackevents.MessageEvent{SubType: "message_deleted", Channel: "C1", ChannelType: "group", PreviousMessage: prev}
+	if _, after, ok := edited(ev); !ok || after.Text != "" {
+		t.Errorf("should return a deleted message without text, got %+v %t", after, ok)
+	}
+
+	reply := &slackevents.MessageEvent{BotID: "B1", Text: "Congrats <@U2>!"}
+	for _, ev := range []*slackevents.MessageEvent{
+		{SubType: "message_changed", ChannelType: "channel", PreviousMessage: reply, Message: reply},
+		{SubType: "message_deleted", ChannelType: "im", PreviousMessage: prev},
+		{User: "U1", ChannelType: "channel"},
+	} {
+		if _, _, ok := edited(ev); ok {
+			t.Errorf("should ignore %+v", ev)
+		}
+	}
+}
+
+func TestCorrectVotes(t *testing.T) {
+	s := bot.NewMemoryStore()
+	b := &bot.SlackBot{Store: s}
+
+	before := post{User: "U1", Channel: "C1", Text: "<@U2>++ <@U3>++ kubernetes++", TimeStamp: "1.1"}
+	for _, v := range identifyPlusPlus(before.Text) {
+		updateScore(b, "T123", "Ev1", before, v)
+	}
+
+	after := before
+	after.Text = "<@U2>-- for breaking the build, kubernetes++"
+	correctVotes(b, "", "T123", bot.Settings{}, "Ev2", before, after)
+
+	for user, want := range map[string]int{"U2": -1, "U3": 0} {
+		if score, _ := s.GetScore("T123", user); score != want {
+			t.Errorf("should correct the score for %s to %d, got %d", user, want, score)
+		}
+	}
+	if score, _ := s.GetScore(bot.ThingsTeam("T123"), "kubernetes"); score != 1 {
+		t.Error("should leave votes that haven't changed, got:", score)
+	}
+
+	correctVotes(b, "", "T123", bot.Settings{}, "Ev2", before, after)
+	if score, _ := s.GetScore("T123", "U2"); score != -1 {
+		t.Error("should only correct once when Slack retries, got:", score)
+	}
+
+	deleted := after
+	deleted.Text = ""
+	correctVotes(b, "", "T123", bot.Settings{}, "Ev3", after, deleted)
+	if score, _ := s.GetScore(bot.ThingsTeam("T123"), "kubernetes"); score != 0 {
+		t.Error("should take back points when the message is deleted, got:", score)
+	}
+}
+
+func TestCastVote(t *testing.T) {
+	s := bot.NewMemoryStore()
+	b := &bot.SlackBot{Store: s}
+	p := post{User: "U1", Channel: "C1", Text: "<@U1>++ <@U2>++", TimeStamp: "1.1"}
+
+	line, counted := castVote(b, "", "T123", bot.Settings{}, "Ev1", p, vote{User: "U1", Delta: 1}, "")
+	if counted || !strings.Contains(line, "try patting yourself on the back") {
+		t.Errorf("should refuse votes for yourself, got %q %t", line, counted)
+	}
+
+	line, counted = castVote(b, "", "T123", bot.Settings{}, "Ev1", p, vote{User: "U2", Delta: 1}, "")
+	if !counted || !strings.Contains(line, "Congrats <@U2>! Score now at 1") {
+		t.Errorf("should count the vote, got %q %t", line, counted)
+	}
+
+	line, counted = castVote(b, "", "T123", bot.Settings{}, "Ev1", p, vote{User: "U2", Delta: 1}, "")
+	if counted || line != "" {
+		t.Errorf("should ignore the vote when Slack retries, got %q %t", line, counted)
+	}
+}
+
+func TestThread(t *testing.T) {
+	if ts := (post{TimeStamp: "1.1"}).thread(); ts != "1.1" {
+		t.Error("should start a thread on the message, got:", ts)
+	}
+	if ts := (post{TimeStamp: "1.2", ThreadTS: "1.1"}).thread(); ts != "1.1" {
+		t.Error("should reply in the thread the message is in, got:", ts)
+	}
+}
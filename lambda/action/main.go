@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/billglover/buddybot/action"
+	"github.com/billglover/buddybot/bot"
+)
+
+func main() {
+	b, err := bot.New()
+	if err != nil {
+		fmt.Println("ERROR: unable to initiate the bot:", err)
+		os.Exit(1)
+	}
+
+	lambda.Start(action.Handler(b))
+}
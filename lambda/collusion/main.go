@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/billglover/buddybot/bot"
+)
+
+func main() {
+	b, err := bot.New()
+	if err != nil {
+		fmt.Println("ERROR: unable to initiate the bot:", err)
+		os.Exit(1)
+	}
+
+	// collusion runs on a schedule, reporting point trading over the last
+	// window to each workspace's admins
+	lambda.Start(func(e events.CloudWatchEvent) error {
+		until := time.Now()
+		n, err := b.ReportCollusion(until.Add(-bot.DefaultCollusionWindow), until, bot.DefaultCollusionThreshold, false)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("INFO: reported on %d teams\n", n)
+		return nil
+	})
+}
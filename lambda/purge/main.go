@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/billglover/buddybot/bot"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	b, err := bot.New()
+	if err != nil {
+		fmt.Println("ERROR: unable to initiate the bot:", err)
+		os.Exit(1)
+	}
+
+	// purge runs on a schedule, deleting the data of workspaces whose grace
+	// period has passed
+	lambda.Start(func(e events.CloudWatchEvent) error {
+		n, err := b.PurgeUninstalled()
+		if err != nil {
+			return errors.Wrapf(err, "purged %d workspaces before failing", n)
+		}
+
+		fmt.Printf("INFO: purged %d workspaces\n", n)
+		return nil
+	})
+}